@@ -0,0 +1,52 @@
+package sitemapper
+
+import "slices"
+
+// BrokenLink is a link that couldn't be fetched during the latest crawl.
+type BrokenLink struct {
+	// URL is the normalized URL of the link.
+	URL string
+
+	// StatusCode is the HTTP status code the server responded with. It's 0 if the request
+	// failed without a response, like when the server couldn't be reached.
+	StatusCode int
+
+	// FoundOn is the page the link was found on. It's empty for the starting URL and for URLs
+	// that didn't come from a page, like the ones from a sitemap linked in robots.txt.
+	FoundOn string
+}
+
+// BrokenLinks returns the internal links that couldn't be fetched during the latest crawl, like
+// the ones that responded with a 404, sorted by URL. Each link is reported along with the status
+// code and the page it was found on, which turns SiteMapper into a lightweight link checker.
+//
+// Links that were skipped on purpose, like the ones disallowed by robots.txt or the ones that
+// aren't pages, aren't considered broken.
+func (mapper *SiteMapper) BrokenLinks() []BrokenLink {
+	return mapper.spider.getBrokenLinks()
+}
+
+// getBrokenLinks retrieves the broken links of the latest crawl.
+func (crawler *crawler) getBrokenLinks() []BrokenLink {
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	return slices.Clone(crawler.brokenLinks)
+}
+
+// recordBrokenLink records a link that couldn't be fetched during the current crawl. Only the
+// first page a link was found on is kept.
+func (crawler *crawler) recordBrokenLink(link string, referrer string, statusCode int) {
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	if _, has := crawler.broken[link]; has {
+		return
+	}
+
+	crawler.broken[link] = BrokenLink{
+		URL:        link,
+		StatusCode: statusCode,
+		FoundOn:    referrer,
+	}
+}
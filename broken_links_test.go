@@ -0,0 +1,96 @@
+package sitemapper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCrawlBrokenLinks(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/about">About</a><a href="/missing">Missing</a>`))
+	})
+	mux.HandleFunc("GET /about", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/error">Error</a><a href="/missing">Missing</a>`))
+	})
+	mux.HandleFunc("GET /error", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	c.crawl(context.Background(), "/")
+
+	expected := []BrokenLink{
+		{URL: mockServer.URL + "/error", StatusCode: http.StatusInternalServerError, FoundOn: mockServer.URL + "/about"},
+		{URL: mockServer.URL + "/missing", StatusCode: http.StatusNotFound, FoundOn: mockServer.URL},
+	}
+
+	if brokenLinks := c.getBrokenLinks(); !reflect.DeepEqual(brokenLinks, expected) {
+		t.Errorf("Expected broken links %v, got %v", expected, brokenLinks)
+	}
+
+	// Fixing the links should clear them from the next report.
+	mux.HandleFunc("GET /missing", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<h1>Found</h1>`))
+	})
+
+	c.crawl(context.Background(), "/")
+
+	expected = expected[:1]
+	if brokenLinks := c.getBrokenLinks(); !reflect.DeepEqual(brokenLinks, expected) {
+		t.Errorf("Expected broken links %v, got %v", expected, brokenLinks)
+	}
+}
+
+func TestCrawlDropsGoneLinks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/gone">Gone</a><a href="/missing">Missing</a><a href="/error">Error</a><a href="/slow">Slow</a>`))
+	})
+	mux.HandleFunc("GET /gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	mux.HandleFunc("GET /error", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("GET /slow", func(w http.ResponseWriter, r *http.Request) {
+		// Cancel the crawl so that the links it didn't get to are kept.
+		cancel()
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	c.links = map[string]crawlerURL{
+		mockServer.URL + "/gone":    {link: mockServer.URL + "/gone"},
+		mockServer.URL + "/missing": {link: mockServer.URL + "/missing"},
+		mockServer.URL + "/error":   {link: mockServer.URL + "/error"},
+		mockServer.URL + "/other":   {link: mockServer.URL + "/other"},
+	}
+
+	c.crawl(ctx, "/")
+
+	links := make(map[string]bool)
+	for _, link := range c.getLinks() {
+		links[link.link] = true
+	}
+
+	if links[mockServer.URL+"/gone"] || links[mockServer.URL+"/missing"] {
+		t.Errorf("Expected the links that returned 404 or 410 to be dropped, got %v", links)
+	}
+
+	// Temporary errors and pages the crawl didn't get to shouldn't be dropped.
+	if !links[mockServer.URL+"/error"] || !links[mockServer.URL+"/other"] {
+		t.Errorf("Expected the other known links to be kept, got %v", links)
+	}
+}
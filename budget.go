@@ -0,0 +1,91 @@
+package sitemapper
+
+import (
+	"context"
+	"net/http"
+)
+
+// RequestsUsed returns the number of requests the crawler has sent over the lifetime of the
+// SiteMapper, across all crawls. It counts the requests for pages as well as the ones for
+// robots.txt, linked sitemaps and the preflight check, whether or not a request budget has been set.
+func (mapper *SiteMapper) RequestsUsed() int {
+	return mapper.spider.getRequestsUsed()
+}
+
+// getRequestsUsed retrieves the number of requests sent so far.
+func (crawler *crawler) getRequestsUsed() int {
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	return crawler.requestsUsed
+}
+
+// budgetExhausted checks whether the request budget has been used up.
+func (crawler *crawler) budgetExhausted() bool {
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	return crawler.requestBudget > 0 && crawler.requestsUsed >= crawler.requestBudget
+}
+
+// useRequest counts a request against the request budget. It returns false, and stops the
+// current crawl, if the budget has been used up, in which case the request shouldn't be sent.
+func (crawler *crawler) useRequest() bool {
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	if crawler.requestBudget > 0 && crawler.requestsUsed >= crawler.requestBudget {
+		if crawler.cancelCrawl != nil {
+			crawler.cancelCrawl(ErrRequestBudgetExhausted)
+		}
+
+		return false
+	}
+
+	crawler.requestsUsed++
+
+	return true
+}
+
+// budgetTransport is the transport of the crawler's HTTP client that counts every request against
+// the request budget before it's sent.
+type budgetTransport struct {
+	crawler *crawler
+	base    http.RoundTripper
+}
+
+// RoundTrip sends the request through the base transport unless the request budget has been used up.
+func (transport *budgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !transport.crawler.useRequest() {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+
+		return nil, ErrRequestBudgetExhausted
+	}
+
+	base := transport.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return base.RoundTrip(req)
+}
+
+// withRequestBudget returns the context of a crawl that gets cancelled with
+// ErrRequestBudgetExhausted as soon as the request budget has been used up.
+func (crawler *crawler) withRequestBudget(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	crawler.mutex.Lock()
+	crawler.cancelCrawl = cancel
+	crawler.mutex.Unlock()
+
+	return ctx, func() {
+		crawler.mutex.Lock()
+		crawler.cancelCrawl = nil
+		crawler.mutex.Unlock()
+
+		cancel(nil)
+	}
+}
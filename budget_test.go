@@ -0,0 +1,87 @@
+package sitemapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCrawlRequestBudget(t *testing.T) {
+	var requests atomic.Int32
+
+	// Every page links to the next one.
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fmt.Fprintf(w, `<a href="%s/next">Next</a>`, strings.TrimSuffix(r.URL.Path, "/"))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	var errs atomic.Int32
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) { errs.Add(1) })
+	c.requestBudget = 3
+	mapper := &SiteMapper{spider: c, domain: mockServer.URL}
+
+	if err := c.crawl(context.Background(), "/"); !errors.Is(err, ErrRequestBudgetExhausted) {
+		t.Errorf("Expected the crawl to return ErrRequestBudgetExhausted, got %v", err)
+	}
+
+	if used := mapper.RequestsUsed(); used != 3 || requests.Load() != 3 {
+		t.Errorf("Expected 3 requests to be used and sent, got %d used and %d sent", used, requests.Load())
+	}
+
+	if links := c.getLinks(); len(links) != 3 {
+		t.Errorf("Expected the 3 crawled pages to be kept, got %d", len(links))
+	}
+
+	if errs.Load() != 1 {
+		t.Errorf("Expected 1 error to be logged, got %d", errs.Load())
+	}
+
+	// No more requests are sent once the budget is used up.
+	if err := c.crawl(context.Background(), "/"); !errors.Is(err, ErrRequestBudgetExhausted) {
+		t.Errorf("Expected the next crawl to return ErrRequestBudgetExhausted, got %v", err)
+	}
+
+	if used := mapper.RequestsUsed(); used != 3 || requests.Load() != 3 {
+		t.Errorf("Expected no more requests to be sent, got %d used and %d sent", used, requests.Load())
+	}
+}
+
+func TestRequestsUsedWithoutBudget(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/page1">Page 1</a>`))
+	})
+	mux.HandleFunc("GET /page1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<h1>Page 1</h1>`))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	c.respectRobotsTxt = true
+	mapper := &SiteMapper{spider: c, domain: mockServer.URL}
+
+	if err := c.crawl(context.Background(), "/"); err != nil {
+		t.Fatalf("Expected the crawl to succeed, got %v", err)
+	}
+
+	if err := c.crawl(context.Background(), "/"); err != nil {
+		t.Fatalf("Expected the crawl to succeed, got %v", err)
+	}
+
+	// Both crawls request robots.txt and the two pages.
+	if used := mapper.RequestsUsed(); used != 6 {
+		t.Errorf("Expected 6 requests to be used, got %d", used)
+	}
+}
@@ -0,0 +1,38 @@
+package sitemapper
+
+import (
+	"bufio"
+	"errors"
+	"io"
+
+	"golang.org/x/net/html/charset"
+)
+
+// charsetPrescanBytes is the number of bytes at the start of a page that are searched for a
+// <meta> tag declaring the charset, the same number browsers use.
+const charsetPrescanBytes = 1024
+
+// utf8Reader returns a reader that transcodes the page read from r to UTF-8 so that the links on
+// pages that aren't served as UTF-8 are read correctly. The charset is determined with
+// charset.DetermineEncoding, from a byte order mark, the Content-Type header or a <meta> tag at
+// the start of the page. Pages without any of those are read as UTF-8 if they're valid UTF-8 and
+// as windows-1252 otherwise, the same way browsers guess.
+//
+// Every charset in the WHATWG Encoding Standard is supported, like Shift_JIS, EUC-KR, KOI8-R or
+// windows-1251. An error is only returned if the start of the page couldn't be read.
+func utf8Reader(r io.Reader, contentType string) (io.Reader, error) {
+	buffered := bufio.NewReaderSize(r, charsetPrescanBytes)
+
+	// Peek returns the whole page along with io.EOF when it's shorter than the prescan.
+	prescan, err := buffered.Peek(charsetPrescanBytes)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	encoding, name, _ := charset.DetermineEncoding(prescan, contentType)
+	if name == "utf-8" {
+		return buffered, nil
+	}
+
+	return encoding.NewDecoder().Reader(buffered), nil
+}
@@ -0,0 +1,71 @@
+package sitemapper
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// decodeToUTF8 reads the body through utf8Reader.
+func decodeToUTF8(body []byte, contentType string) []byte {
+	reader, err := utf8Reader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return nil
+	}
+
+	decoded, _ := io.ReadAll(reader)
+	return decoded
+}
+
+func TestDecodeToUTF8(t *testing.T) {
+	// "café" and "€" encoded as windows-1252.
+	latin1 := []byte("<a href=\"/caf\xe9\">\x80</a>")
+
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		expected    []byte
+	}{
+		{"UTF-8 header", []byte("<a href=\"/café\">€</a>"), "text/html; charset=utf-8", []byte("<a href=\"/café\">€</a>")},
+		{"ISO-8859-1 header", latin1, "text/html; charset=ISO-8859-1", []byte("<a href=\"/café\">€</a>")},
+		{"windows-1252 header", latin1, "text/html; charset=\"windows-1252\"", []byte("<a href=\"/café\">€</a>")},
+		{"Meta charset", append([]byte(`<meta charset="latin1">`), latin1...), "text/html", []byte(`<meta charset="latin1"><a href="/café">€</a>`)},
+		{"Meta http-equiv", append([]byte(`<meta http-equiv="Content-Type" content="text/html; charset=iso-8859-1">`), latin1...), "", []byte(`<meta http-equiv="Content-Type" content="text/html; charset=iso-8859-1"><a href="/café">€</a>`)},
+		{"Header wins over meta", append([]byte(`<meta charset="latin1">`), "é"...), "text/html; charset=utf-8", []byte(`<meta charset="latin1">é`)},
+		{"Shift_JIS header", []byte("<a href=\"/\x93\xfa\x96{\">"), "text/html; charset=shift_jis", []byte("<a href=\"/日本\">")},
+		{"KOI8-R meta", []byte("<meta charset=\"koi8-r\"><a href=\"/\xd3\xd4\">"), "text/html", []byte("<meta charset=\"koi8-r\"><a href=\"/ст\">")},
+		{"Unknown charset", []byte("<a href=\"/café\">"), "text/html; charset=x-unknown", []byte("<a href=\"/café\">")},
+		{"No charset UTF-8", []byte("<a href=\"/café\">"), "text/html", []byte("<a href=\"/café\">")},
+		{"No charset guess", latin1, "text/html", []byte("<a href=\"/café\">€</a>")},
+	}
+
+	for _, test := range tests {
+		if decoded := decodeToUTF8(test.body, test.contentType); !bytes.Equal(decoded, test.expected) {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, decoded)
+		}
+	}
+}
+
+func TestUTF8ReaderLargePage(t *testing.T) {
+	// The page is larger than the prescan and the buffers used to transcode it.
+	body := `<meta charset="windows-1252">` + strings.Repeat("caf\xe9 \x80 ", 5000)
+	expected := `<meta charset="windows-1252">` + strings.Repeat("café € ", 5000)
+
+	if decoded := decodeToUTF8([]byte(body), "text/html"); string(decoded) != expected {
+		t.Errorf("Expected %d bytes of transcoded text, got %d bytes", len(expected), len(decoded))
+	}
+}
+
+func TestExtractLinksLatin1(t *testing.T) {
+	c := newCrawler("http://example.com", nil, nil, nil)
+
+	body := decodeToUTF8([]byte("<a href=\"/caf\xe9\">Caf\xe9</a>"), "text/html; charset=iso-8859-1")
+
+	expected := []string{"http://example.com/caf%C3%A9"}
+	if links := c.extractLinks(bytes.NewReader(body)); !slices.Equal(links, expected) {
+		t.Errorf("Expected %v, got %v", expected, links)
+	}
+}
@@ -0,0 +1,54 @@
+package sitemapper
+
+import (
+	"context"
+	"maps"
+)
+
+// crawlCheckpoint is a snapshot of an unfinished crawl from which it can be resumed.
+type crawlCheckpoint struct {
+	// queue are the URLs that still have to be crawled.
+	queue []crawlItem
+
+	// visited are the pages that had already been crawled.
+	visited map[string]crawlerURL
+}
+
+// resume continues the crawl from the latest checkpoint. A new crawl from the given URL is
+// started if there is no checkpoint.
+func (crawler *crawler) resume(ctx context.Context, url string) error {
+	return crawler.run(ctx, url, true)
+}
+
+// saveCheckpoint takes a checkpoint of the crawl that is using the queue.
+func (crawler *crawler) saveCheckpoint(queue *crawlQueue) {
+	items := queue.snapshot()
+
+	crawler.mutex.Lock()
+	crawler.checkpoint = &crawlCheckpoint{
+		queue:   items,
+		visited: maps.Clone(crawler.visited),
+	}
+	crawler.mutex.Unlock()
+
+	if crawler.onCheckpoint != nil {
+		crawler.onCheckpoint()
+	}
+}
+
+// clearCheckpoint removes the latest checkpoint once a crawl has finished.
+func (crawler *crawler) clearCheckpoint() {
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	crawler.checkpoint = nil
+}
+
+// isVisited checks whether the link has been visited during the current crawl.
+func (crawler *crawler) isVisited(link string) bool {
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	_, has := crawler.visited[link]
+	return has
+}
@@ -0,0 +1,113 @@
+package sitemapper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// hasCheckpoint checks whether the crawler has a checkpoint to resume from.
+func hasCheckpoint(c *crawler) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.checkpoint != nil
+}
+
+func TestResumeCrawl(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mutex sync.Mutex
+	requests := make(map[string]int)
+
+	// Every page links to the next one, and the crawl gets cancelled while fetching /3.
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests[r.URL.Path]++
+		mutex.Unlock()
+
+		page := 0
+		fmt.Sscanf(r.URL.Path, "/%d", &page)
+
+		if page == 3 {
+			cancel()
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><a href="/%d">Next</a></body></html>`, page+1)
+	})
+	mux.HandleFunc("/5", func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests[r.URL.Path]++
+		mutex.Unlock()
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body>The end</body></html>`))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	checkpoints := 0
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	c.checkpointEvery = 1
+	c.onCheckpoint = func() {
+		checkpoints++
+	}
+
+	if err := c.crawl(ctx, "/"); err == nil {
+		t.Fatal("Expected the crawl to be cancelled")
+	}
+
+	if checkpoints == 0 || !hasCheckpoint(c) {
+		t.Fatal("Expected a checkpoint to be taken")
+	}
+
+	// The checkpoint should survive a restart.
+	var buffer bytes.Buffer
+
+	if err := c.saveState(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	restarted.checkpointEvery = 1
+
+	if err := restarted.loadState(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := restarted.resume(context.Background(), "/"); err != nil {
+		t.Fatal(err)
+	}
+
+	if hasCheckpoint(restarted) {
+		t.Error("Expected the checkpoint to be cleared once the crawl finished")
+	}
+
+	links := make(map[string]bool)
+	for _, link := range restarted.getLinks() {
+		links[link.link] = true
+	}
+
+	expected := []string{"", "/1", "/2", "/3", "/4", "/5"}
+	for _, path := range expected {
+		if !links[mockServer.URL+path] {
+			t.Errorf("Expected '%s' to be crawled", mockServer.URL+path)
+		}
+	}
+
+	// The pages that were crawled before the checkpoint shouldn't be fetched again.
+	for _, path := range []string{"/", "/1", "/2"} {
+		if requests[path] != 1 {
+			t.Errorf("Expected '%s' to be fetched once, got %d", path, requests[path])
+		}
+	}
+}
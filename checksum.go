@@ -0,0 +1,229 @@
+package sitemapper
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// invisibleTextTags are the tags whose contents aren't visible on the page.
+var invisibleTextTags = []string{"script", "style", "template", "noscript"}
+
+// voidElements are the tags that never have contents or an end tag.
+var voidElements = []string{"area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "source", "track", "wbr"}
+
+// elementSelector is a simple CSS selector, like "footer", ".counter", "#updated" or
+// "div.ad.banner", that matches elements on their tag name, ID and classes.
+type elementSelector struct {
+	tag     string
+	id      string
+	classes []string
+}
+
+// parseElementSelector parses a selector made up of an optional tag name followed by any number
+// of ".class" and "#id" parts.
+func parseElementSelector(selector string) (elementSelector, error) {
+	invalid := fmt.Errorf(`invalid selector: %q must be a tag name, class or ID like "footer", ".counter" or "#updated"`, selector)
+
+	s := strings.TrimSpace(selector)
+	if s == "" {
+		return elementSelector{}, invalid
+	}
+
+	end := strings.IndexAny(s, ".#")
+	if end < 0 {
+		end = len(s)
+	}
+
+	parsed := elementSelector{tag: strings.ToLower(s[:end])}
+	if parsed.tag != "" && !isSelectorName(parsed.tag) {
+		return elementSelector{}, invalid
+	}
+
+	for rest := s[end:]; rest != ""; {
+		kind := rest[0]
+		rest = rest[1:]
+
+		end := strings.IndexAny(rest, ".#")
+		if end < 0 {
+			end = len(rest)
+		}
+
+		name := rest[:end]
+		rest = rest[end:]
+
+		if !isSelectorName(name) {
+			return elementSelector{}, invalid
+		}
+
+		if kind == '.' {
+			parsed.classes = append(parsed.classes, name)
+		} else if parsed.id == "" {
+			parsed.id = name
+		} else {
+			// An element can't have two IDs.
+			return elementSelector{}, invalid
+		}
+	}
+
+	return parsed, nil
+}
+
+// isSelectorName checks whether the name only contains letters, digits, hyphens and underscores.
+func isSelectorName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matches checks whether the selector matches the element of the start tag.
+func (selector elementSelector) matches(token html.Token) bool {
+	if selector.tag != "" && selector.tag != token.Data {
+		return false
+	}
+
+	if selector.id != "" {
+		if id, _ := getAttr(token, "id"); strings.TrimSpace(id) != selector.id {
+			return false
+		}
+	}
+
+	if len(selector.classes) > 0 {
+		class, _ := getAttr(token, "class")
+		classes := strings.Fields(class)
+
+		for _, name := range selector.classes {
+			if !slices.Contains(classes, name) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// contentHasher computes the checksum of a page while it's being tokenized, for when the checksum
+// can't simply be computed over the raw body. That's the case when only the visible text should
+// be hashed or when some elements should be left out of the checksum.
+type contentHasher struct {
+	hash hash.Hash
+
+	// visibleText determines whether the visible text is hashed instead of the raw tokens.
+	visibleText bool
+
+	// ignore are the selectors of the elements that are left out of the checksum along with
+	// their contents.
+	ignore []elementSelector
+
+	// ignoredTag is the tag of the ignored element that the tokenizer is inside of, and
+	// ignoredDepth is the number of nested elements with that tag it's inside of. It's 0 when
+	// the tokenizer isn't inside of an ignored element.
+	ignoredTag   string
+	ignoredDepth int
+
+	// invisibleDepth is the number of tags whose contents aren't visible that the tokenizer is
+	// inside of.
+	invisibleDepth int
+}
+
+// newContentHasher creates the contentHasher for the crawler's change detection settings. It
+// returns nil when the checksum is computed over the raw body instead.
+func (crawler *crawler) newContentHasher() *contentHasher {
+	visibleText := crawler.changeDetectionMode == ChangeDetectionVisibleText
+	if !visibleText && len(crawler.checksumIgnoreSelectors) == 0 {
+		return nil
+	}
+
+	return &contentHasher{
+		hash:        sha256.New(),
+		visibleText: visibleText,
+		ignore:      crawler.checksumIgnoreSelectors,
+	}
+}
+
+// startTag handles a start or self-closing tag along with its raw markup.
+func (hasher *contentHasher) startTag(token html.Token, raw []byte) {
+	selfClosing := token.Type == html.SelfClosingTagToken || slices.Contains(voidElements, token.Data)
+
+	if hasher.ignoredDepth > 0 {
+		if token.Data == hasher.ignoredTag && !selfClosing {
+			hasher.ignoredDepth++
+		}
+
+		return
+	}
+
+	if slices.ContainsFunc(hasher.ignore, func(selector elementSelector) bool { return selector.matches(token) }) {
+		if !selfClosing {
+			hasher.ignoredTag = token.Data
+			hasher.ignoredDepth = 1
+		}
+
+		return
+	}
+
+	if !selfClosing && slices.Contains(invisibleTextTags, token.Data) {
+		hasher.invisibleDepth++
+	}
+
+	hasher.rawToken(raw)
+}
+
+// endTag handles an end tag along with its raw markup.
+func (hasher *contentHasher) endTag(name string, raw []byte) {
+	if hasher.ignoredDepth > 0 {
+		if name == hasher.ignoredTag {
+			hasher.ignoredDepth--
+		}
+
+		return
+	}
+
+	if slices.Contains(invisibleTextTags, name) {
+		hasher.invisibleDepth = max(hasher.invisibleDepth-1, 0)
+	}
+
+	hasher.rawToken(raw)
+}
+
+// rawToken hashes the raw markup of a token, unless the visible text is hashed instead or the
+// token is inside of an ignored element.
+func (hasher *contentHasher) rawToken(raw []byte) {
+	if hasher.visibleText || hasher.ignoredDepth > 0 {
+		return
+	}
+
+	hasher.hash.Write(raw)
+}
+
+// text hashes the unescaped text of a text token if the visible text is hashed and the text is
+// visible. The whitespace is normalized so that reformatting the markup isn't a change.
+func (hasher *contentHasher) text(text []byte) {
+	if !hasher.visibleText || hasher.ignoredDepth > 0 || hasher.invisibleDepth > 0 {
+		return
+	}
+
+	for _, word := range bytes.Fields(text) {
+		hasher.hash.Write(word)
+		hasher.hash.Write([]byte{' '})
+	}
+}
+
+// sum returns the hex encoded checksum.
+func (hasher *contentHasher) sum() string {
+	return hex.EncodeToString(hasher.hash.Sum(nil))
+}
@@ -0,0 +1,149 @@
+// Command sitemapper crawls a site once and writes its sitemap to a file, which makes SiteMapper
+// usable as a standalone tool. Every flag maps onto one of the setters of SiteMapperOptions.
+//
+// Usage:
+//
+//	sitemapper -domain https://example.com -out sitemap.xml -attrs hx-get,src
+//
+// Run "sitemapper -h" for the full list of flags.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/PsionicAlch/sitemapper"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "sitemapper:", err)
+		}
+
+		os.Exit(1)
+	}
+}
+
+// run parses the flags, crawls the site and writes the sitemap. The sitemap is written to stdout
+// when the output file is "-", while the logs always go to stderr.
+func run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := flag.NewFlagSet("sitemapper", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	domain := flags.String("domain", "", "the domain to crawl, like https://example.com (required)")
+	baseDomain := flags.String("base-domain", "", "the domain used in the sitemap instead of the crawled domain (defaults to -domain)")
+	out := flags.String("out", "sitemap.xml", `the file the sitemap is written to, or "-" for stdout`)
+	start := flags.String("start", "/", "the URL path the crawl starts from")
+	attrs := flags.String("attrs", "", "comma separated HTML attributes that contain links on top of <a href>, like hx-get,src")
+	exclude := flags.String("exclude", "", "comma separated regex patterns of the URLs to leave out of the sitemap")
+	concurrency := flags.Int("concurrency", 1, "the number of pages fetched in parallel")
+	maxDepth := flags.Int("max-depth", 0, "the maximum number of links followed from the starting URL (0 for no limit)")
+	userAgent := flags.String("user-agent", sitemapper.DefaultUserAgent, "the User-Agent header sent with every request")
+	timeout := flags.Duration("timeout", 0, "the timeout of every request (0 for no timeout)")
+	delay := flags.Duration("delay", 0, "the minimum delay between two requests")
+	maxDuration := flags.Duration("max-duration", 0, "the maximum duration of the crawl, after which the pages found so far are written (0 for no limit)")
+	robots := flags.Bool("robots", false, "respect robots.txt")
+	nofollow := flags.Bool("nofollow", false, "don't follow links marked as nofollow")
+	followRedirects := flags.Bool("follow-redirects", false, "follow redirects within the domain")
+	verbose := flags.Bool("verbose", false, "log every page that is crawled")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *domain == "" {
+		flags.Usage()
+		return errors.New("-domain is required")
+	}
+
+	if *baseDomain == "" {
+		*baseDomain = *domain
+	}
+
+	opts := []sitemapper.Option{
+		sitemapper.WithDomain(*domain),
+		sitemapper.WithStartingURL(*start),
+		sitemapper.WithConcurrency(*concurrency),
+		sitemapper.WithMaxDepth(*maxDepth),
+		sitemapper.WithUserAgent(*userAgent),
+		sitemapper.WithRequestTimeout(*timeout),
+		sitemapper.WithCrawlDelay(*delay),
+		sitemapper.WithMaxCrawlDuration(*maxDuration),
+		sitemapper.WithRespectRobotsTxt(*robots),
+		sitemapper.WithRespectNofollow(*nofollow),
+		sitemapper.WithFollowRedirects(*followRedirects),
+		sitemapper.WithErrorLogger(func(err error) {
+			fmt.Fprintln(stderr, "error:", err)
+		}),
+	}
+
+	if attributes := splitList(*attrs); len(attributes) > 0 {
+		opts = append(opts, sitemapper.WithLinkAttributes(attributes...))
+	}
+
+	if *verbose {
+		opts = append(opts, sitemapper.WithInfoLogger(func(msg string) {
+			fmt.Fprintln(stderr, msg)
+		}))
+	}
+
+	mapper, err := sitemapper.NewSiteMapperWithOptions(opts...)
+	if err != nil {
+		return err
+	}
+
+	// The site is only crawled once, so the background crawls aren't needed.
+	mapper.Stop()
+
+	started := time.Now()
+	if err := mapper.CrawlWithContext(ctx); err != nil {
+		// A crawl that ran out of time or got interrupted still found some of the pages.
+		if !errors.Is(err, sitemapper.ErrMaxCrawlDuration) && !errors.Is(err, context.Canceled) {
+			return fmt.Errorf("crawl failed: %w", err)
+		}
+
+		fmt.Fprintln(stderr, "warning: crawl stopped early:", err)
+	}
+
+	sitemap, err := mapper.GenerateSitemapFiltered(*baseDomain, splitList(*exclude), nil)
+	if err != nil {
+		return err
+	}
+
+	if *out == "-" {
+		_, err = io.WriteString(stdout, sitemap)
+		return err
+	}
+
+	if err := os.WriteFile(*out, []byte(sitemap), 0o644); err != nil {
+		return err
+	}
+
+	stats := mapper.Stats()
+	fmt.Fprintf(stderr, "Crawled %d pages in %s and wrote the sitemap to %s\n", stats.PagesCrawled, time.Since(started).Round(time.Millisecond), *out)
+
+	return nil
+}
+
+// splitList splits a comma separated flag into its trimmed, non-empty values.
+func splitList(value string) []string {
+	var values []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/about">About</a><button hx-get="/htmx">Load</button><a href="/admin">Admin</a>`))
+	})
+	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<h1>Page</h1>`))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	out := filepath.Join(t.TempDir(), "sitemap.xml")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-domain", mockServer.URL, "-base-domain", "https://example.com", "-out", out, "-attrs", "hx-get, src", "-exclude", "/admin"}
+
+	if err := run(context.Background(), args, &stdout, &stderr); err != nil {
+		t.Fatalf("Expected no error, got %v (%s)", err, stderr.String())
+	}
+
+	sitemap, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Expected the sitemap to be written, got %v", err)
+	}
+
+	for _, location := range []string{"https://example.com</loc>", "https://example.com/about</loc>", "https://example.com/htmx</loc>"} {
+		if !bytes.Contains(sitemap, []byte(location)) {
+			t.Errorf("Expected the sitemap to contain %q, got %s", location, sitemap)
+		}
+	}
+
+	if bytes.Contains(sitemap, []byte("/admin")) {
+		t.Errorf("Expected /admin to be left out of the sitemap, got %s", sitemap)
+	}
+
+	// The sitemap can be written to stdout as well.
+	stdout.Reset()
+	if err := run(context.Background(), []string{"-domain", mockServer.URL, "-out", "-"}, &stdout, &stderr); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !strings.HasPrefix(stdout.String(), "<?xml") {
+		t.Errorf("Expected the sitemap on stdout, got %q", stdout.String())
+	}
+}
+
+func TestRunInvalidFlags(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"-domain", "not a url"},
+		{"-domain", "http://localhost", "-concurrency", "0"},
+		{"-unknown"},
+	}
+
+	for _, args := range tests {
+		var stdout, stderr bytes.Buffer
+		if err := run(context.Background(), args, &stdout, &stderr); err == nil {
+			t.Errorf("Expected an error for %q", args)
+		}
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	if values := splitList(" hx-get, ,src,"); strings.Join(values, "|") != "hx-get|src" {
+		t.Errorf("Expected [hx-get src], got %v", values)
+	}
+
+	if values := splitList(""); values != nil {
+		t.Errorf("Expected no values, got %v", values)
+	}
+}
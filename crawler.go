@@ -2,22 +2,36 @@ package sitemapper
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"maps"
+	"mime"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
+	"path"
+	"regexp"
 	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"golang.org/x/net/html"
+	"golang.org/x/net/publicsuffix"
 )
 
+// defaultMaxResponseBytes is the maximum size of a response body the crawler reads by default.
+const defaultMaxResponseBytes = 10 << 20
+
+// defaultContentTypes are the media types the crawler parses by default.
+var defaultContentTypes = []string{"text/html", "application/xhtml+xml"}
+
 // crawlerURL represents a URL with its metadata.
 type crawlerURL struct {
 	// link is the URL of the page.
@@ -28,6 +42,69 @@ type crawlerURL struct {
 
 	// lastChanged is timestamp of the last detected change.
 	lastChanged time.Time
+
+	// lastSeen is the time at which the page was last fetched successfully.
+	lastSeen time.Time
+
+	// lastModified is the time from the page's Last-Modified header. It's the zero time
+	// if the server didn't send the header.
+	lastModified time.Time
+
+	// etag is the page's ETag header. It's empty if the server didn't send the header.
+	etag string
+
+	// statusCode is the HTTP status code of the latest response for the page, which is 304 when
+	// it was reused after a 304 Not Modified response.
+	statusCode int
+
+	// size is the size in bytes of the page's body, after it has been decompressed.
+	size int
+
+	// outLinks are the in-domain links found on the page and externalLinks are the links to
+	// pages outside of the domain, if they were collected. They're needed to carry on crawling
+	// when the server responds with 304 Not Modified. outLinks is nil if they aren't known.
+	outLinks      []string
+	externalLinks []string
+
+	// noindex is set when the page asked to not be indexed. The page is still crawled for its
+	// links but it's left out of the sitemap.
+	noindex bool
+
+	// soft404 is set when the page looks like a "not found" page even though it was served with
+	// a 200 status code. Just like with noindex, the page is crawled but left out of the sitemap.
+	soft404 bool
+
+	// images are the URLs of the images found on the page.
+	images []string
+
+	// alternates are the alternate-language versions of the page.
+	alternates []alternateLink
+
+	// videos are the videos embedded in the page.
+	videos []videoInfo
+
+	// manual is set when the page was added with AddURL. It's kept until it's removed, whether
+	// or not a crawl finds it.
+	manual bool
+
+	// crawlOrder is the position at which the page was visited during the crawl that last
+	// visited it.
+	crawlOrder int
+}
+
+// lastMod returns the time the page was last modified. The Last-Modified header is preferred
+// and the time of the last detected change is used when the header was absent.
+func (url crawlerURL) lastMod() time.Time {
+	if !url.lastModified.IsZero() {
+		return url.lastModified
+	}
+
+	return url.lastChanged
+}
+
+// indexable checks whether the page should be listed in the sitemap.
+func (url crawlerURL) indexable() bool {
+	return !url.noindex && !url.soft404
 }
 
 // crawler manages the crawling process within a specific domain.
@@ -35,20 +112,231 @@ type crawler struct {
 	// mutex ensures thread-safe access to shared resources.
 	mutex sync.Mutex
 
+	// crawlMutex ensures that only one crawl runs at a time.
+	crawlMutex sync.Mutex
+
 	// domain represents the domain which should be crawled whilst also ensuring
 	// that links outside of this domain don't get indexed.
 	domain string
 
+	// allowSubdomains determines whether the subdomains of the domain's registrable domain are
+	// treated as part of the domain.
+	allowSubdomains bool
+
+	// ignoreScheme determines whether "http" and "https" links are treated as the same site.
+	ignoreScheme bool
+
 	// linkAttributes are the HTML attributes the crawler should consider as links.
 	linkAttributes []string
 
+	// jsonLinkAttributes are the HTML attributes whose values can be JSON arrays of URLs.
+	jsonLinkAttributes []string
+
+	// concurrency is the number of workers that fetch pages in parallel during a crawl.
+	concurrency int
+
+	// maxDepth is the maximum number of links that will be followed from the starting URL.
+	// A value of 0 or less means that there is no limit.
+	maxDepth int
+
+	// userAgent is the User-Agent header sent with every request.
+	userAgent string
+
+	// httpClient is the user supplied HTTP client. If nil a default client will be used.
+	httpClient *http.Client
+
+	// pageFetcher fetches the HTML of pages instead of the HTTP client, like a headless browser
+	// that renders the page first. If nil the HTTP client is used.
+	pageFetcher func(ctx context.Context, url string) ([]byte, error)
+
+	// linkExtractor finds the links on pages instead of the tokenizer. If nil only the links the
+	// tokenizer finds are used.
+	linkExtractor LinkExtractor
+
+	// pageCallback is called, if set, with every page that has been fetched during a crawl.
+	pageCallback func(url string, statusCode int, body []byte)
+
+	// proxyURL is the proxy the requests are sent through. If nil the client's transport decides.
+	proxyURL *url.URL
+
+	// requestHeaders are the custom headers sent with every request to the domain.
+	requestHeaders http.Header
+
+	// basicAuthUsername and basicAuthPassword are the credentials sent with every request to
+	// the domain. No credentials are sent if the username is empty.
+	basicAuthUsername string
+	basicAuthPassword string
+
+	// cookieJar stores the cookies across requests. If nil the HTTP client's jar is used.
+	cookieJar http.CookieJar
+
+	// cookies are sent with every request, on top of the cookies set by the site.
+	cookies []*http.Cookie
+
+	// followRedirects determines whether the crawler follows redirects to in-domain URLs.
+	followRedirects bool
+
+	// alwaysUpdateLastMod determines whether every visited page counts as changed when it comes
+	// to its last changed time, even when its checksum is the same as during the previous crawl.
+	alwaysUpdateLastMod bool
+
+	// useConditionalRequests determines whether known pages are fetched with If-None-Match and
+	// If-Modified-Since headers so that unchanged pages don't have to be downloaded again.
+	useConditionalRequests bool
+
+	// requestTimeout is the maximum amount of time a single request may take. Zero means
+	// there is no timeout.
+	requestTimeout time.Duration
+
+	// includePatterns are the regexes of which a discovered URL has to match at least one
+	// to be crawled. All URLs are crawled if there are none.
+	includePatterns []*regexp.Regexp
+
+	// excludePatterns are the regexes of which a discovered URL may not match any to be
+	// crawled. They take precedence over includePatterns.
+	excludePatterns []*regexp.Regexp
+
+	// scopePatterns are the regexes of which the path of a discovered URL has to match at least
+	// one to be crawled. All paths are crawled if there are none.
+	scopePatterns []*regexp.Regexp
+
+	// pathCollapsePatterns are the regexes whose capture groups are removed from the path of
+	// every URL the crawler finds.
+	pathCollapsePatterns []*regexp.Regexp
+
+	// soft404Pattern marks the pages whose body it matches as soft 404s. If nil no page is.
+	soft404Pattern *regexp.Regexp
+
+	// changeDetectionMode determines whether the checksum of a page is computed over its raw HTML
+	// or over its visible text.
+	changeDetectionMode ChangeDetectionMode
+
+	// checksumIgnoreSelectors match the elements that are left out of the checksum of a page.
+	checksumIgnoreSelectors []elementSelector
+
+	// trailingSlashPolicy determines whether trailing slashes get removed from, added to or
+	// left alone on URLs.
+	trailingSlashPolicy TrailingSlashPolicy
+
+	// stripQueryParams determines whether the query string gets removed from URLs.
+	stripQueryParams bool
+
+	// ignoreQueryParams are the query parameters that get removed from URLs. They may
+	// contain wildcards like "utm_*".
+	ignoreQueryParams []string
+
+	// maxResponseBytes is the maximum size of a response body. Larger responses are skipped.
+	maxResponseBytes int64
+
+	// allowedContentTypes are the media types of the responses that get parsed. They may contain
+	// wildcards like "text/*". Responses with any other Content-Type are skipped.
+	allowedContentTypes []string
+
+	// respectCanonical determines whether pages get recorded under the canonical URL they declare.
+	respectCanonical bool
+
+	// includeExternalImages determines whether images outside of the domain are collected
+	// along with the in-domain ones.
+	includeExternalImages bool
+
+	// extractVideos determines whether the videos embedded in pages are collected.
+	extractVideos bool
+
+	// includeExternalLinksInGraph determines whether the links to pages outside of the domain
+	// are recorded, so that they show up in the link graph.
+	includeExternalLinksInGraph bool
+
+	// respectNofollow determines whether links marked with rel="nofollow", or on pages with a
+	// nofollow robots meta tag, should be skipped.
+	respectNofollow bool
+
+	// respectRobotsTxt determines whether the crawler should obey the rules in robots.txt.
+	respectRobotsTxt bool
+
+	// robots are the robots.txt rules for the current crawl. A nil value allows everything.
+	robots *robotsRules
+
+	// importLinkedSitemaps determines whether the pages in the sitemaps that robots.txt links to
+	// are crawled as well.
+	importLinkedSitemaps bool
+
+	// crawlDelay is the minimum amount of time between two successive requests.
+	crawlDelay time.Duration
+
+	// maxCrawlDuration is the maximum amount of time a single crawl may take. Zero means there
+	// is no limit.
+	maxCrawlDuration time.Duration
+
+	// requestBudget is the maximum number of requests that are sent across all crawls. Zero
+	// means there is no limit.
+	requestBudget int
+
+	// requestsUsed is the number of requests that have been sent across all crawls.
+	requestsUsed int
+
+	// cancelCrawl stops the current crawl with a cause. It's nil while no crawl is running.
+	cancelCrawl context.CancelCauseFunc
+
+	// fallbackThreshold is the percentage of the known pages that a completed crawl has to find
+	// for its links to replace the known ones. Zero disables the check.
+	fallbackThreshold float64
+
+	// limiter spaces out the requests of the current crawl.
+	limiter *rateLimiter
+
 	// visited is the URLs that have been found in the latest crawl.
 	visited map[string]crawlerURL
 
+	// reserved is the URLs that a worker has taken from the queue during the current crawl. A URL
+	// is reserved before it's fetched, so that it's only fetched once even if it was queued more
+	// than once while another worker was still fetching it.
+	reserved map[string]struct{}
+
 	// links represents all the links that have been discovered throughout the lifespan
 	// of the running application. Useful for keeping track of which links have changed.
 	links map[string]crawlerURL
 
+	// crawlErrors is the number of errors that occurred during the current crawl.
+	crawlErrors int
+
+	// broken are the links that couldn't be fetched during the current crawl, by URL.
+	broken map[string]BrokenLink
+
+	// brokenLinks are the links that couldn't be fetched during the latest crawl.
+	brokenLinks []BrokenLink
+
+	// referrers are the pages that link to each URL in the current crawl, by URL.
+	referrers map[string]map[string]struct{}
+
+	// linkReferrers are the pages that link to each URL in the latest crawl, sorted, by URL.
+	linkReferrers map[string][]string
+
+	// checkpointEvery is the number of pages after which a checkpoint of the crawl is taken.
+	// No checkpoints are taken if it's 0 or less.
+	checkpointEvery int
+
+	// checkpoint is the latest checkpoint of an unfinished crawl. It's nil if there is none.
+	checkpoint *crawlCheckpoint
+
+	// seeds are the URLs that the next crawl queues on top of the starting URL.
+	seeds []string
+
+	// onCheckpoint is called, if set, every time a checkpoint has been taken.
+	onCheckpoint func()
+
+	// stats are the statistics of the latest crawl.
+	stats CrawlStats
+
+	// lastCrawlTime is the time at which the latest successful crawl finished. It's the zero
+	// value if no crawl has finished successfully yet.
+	lastCrawlTime time.Time
+
+	// events is the buffered channel the crawl events are sent on.
+	events chan CrawlEvent
+
+	// metrics receives the measurements made while crawling.
+	metrics Metrics
+
 	// infoLogger is used for logging informational messages. No messages will be logged
 	// if an infoLogger was not passed to SiteMapper.
 	infoLogger func(string)
@@ -61,170 +349,936 @@ type crawler struct {
 // newCrawler creates a new crawler instance.
 func newCrawler(domain string, linkAttributes []string, infoLogger func(string), errorLogger func(error)) *crawler {
 	return &crawler{
-		domain:         domain,
-		linkAttributes: linkAttributes,
-		visited:        make(map[string]crawlerURL),
-		links:          make(map[string]crawlerURL),
-		infoLogger:     infoLogger,
-		errorLogger:    errorLogger,
+		domain:              domain,
+		linkAttributes:      linkAttributes,
+		concurrency:         1,
+		userAgent:           DefaultUserAgent,
+		allowedContentTypes: slices.Clone(defaultContentTypes),
+		maxResponseBytes:    defaultMaxResponseBytes,
+		visited:             make(map[string]crawlerURL),
+		links:               make(map[string]crawlerURL),
+		events:              make(chan CrawlEvent, eventBufferSize),
+		metrics:             noopMetrics{},
+		infoLogger:          infoLogger,
+		errorLogger:         errorLogger,
 	}
 }
 
-// crawl starts crawling from the given URL.
-func (crawler *crawler) crawl(url string) {
-	// Ensure only one goroutine modifies shared state at a time.
-	crawler.mutex.Lock()
-	defer crawler.mutex.Unlock()
+// crawl starts crawling from the given URL. If the context gets cancelled the crawl
+// stops as soon as possible, and the pages that were crawled up until that point are
+// merged into the known links. The context's error is returned in that case, or
+// ErrMaxCrawlDuration if the crawl ran out of time.
+func (crawler *crawler) crawl(ctx context.Context, url string) error {
+	return crawler.run(ctx, url, false)
+}
+
+// run performs a crawl from the given URL. When resuming, the crawl continues from the
+// latest checkpoint instead, if there is one.
+func (crawler *crawler) run(ctx context.Context, url string, resume bool) error {
+	// Ensure only one crawl runs at a time.
+	crawler.crawlMutex.Lock()
+	defer crawler.crawlMutex.Unlock()
 
-	// Reset the visited map for a new crawl.
-	crawler.visited = make(map[string]crawlerURL)
+	start := time.Now()
+
+	// Don't start a crawl that can't send any requests.
+	if crawler.budgetExhausted() {
+		crawler.errorLogger(ErrRequestBudgetExhausted)
+		return ErrRequestBudgetExhausted
+	}
+
+	// Stop the crawl once it has used up the request budget. The workers wind down the same way
+	// they do when the crawl gets cancelled.
+	ctx, cancelBudget := crawler.withRequestBudget(ctx)
+	defer cancelBudget()
+
+	// Stop the crawl once it has used up its time budget. The workers wind down the same way
+	// they do when the crawl gets cancelled.
+	if crawler.maxCrawlDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, crawler.maxCrawlDuration, ErrMaxCrawlDuration)
+		defer cancel()
+	}
 
 	// Normalize the starting URL.
 	normalizedURL, ok := crawler.normalizeURL(url)
 	if !ok {
-		return
+		return nil
 	}
 
-	// Initialize the queue with the starting URL.
-	queue := []string{normalizedURL}
+	// Reset the visited map and the error count for a new crawl, or pick up where the
+	// latest checkpoint left off.
+	crawler.mutex.Lock()
+	checkpoint := crawler.checkpoint
+	if !resume || checkpoint == nil {
+		checkpoint = &crawlCheckpoint{queue: []crawlItem{{link: normalizedURL, depth: 0}}}
+	}
 
-	// Process the queue until it's empty.
-	for len(queue) > 0 {
-		currentURL := queue[0]
+	crawler.visited = maps.Clone(checkpoint.visited)
+	if crawler.visited == nil {
+		crawler.visited = make(map[string]crawlerURL)
+	}
 
-		// Dequeue the first URL.
-		queue = queue[1:]
+	crawler.reserved = make(map[string]struct{})
+	crawler.crawlErrors = 0
+	crawler.broken = make(map[string]BrokenLink)
+	crawler.referrers = make(map[string]map[string]struct{})
+	crawler.mutex.Unlock()
 
-		// Skip the URL if it has already been visited.
-		if _, has := crawler.visited[currentURL]; has {
-			continue
-		}
+	crawler.emit(CrawlEvent{Type: CrawlStarted, URL: normalizedURL})
 
-		// Create an HTTP client that will error on redirects.
-		client := &http.Client{
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				return errors.New("redirects not allowed")
-			},
-		}
+	// Initialize the queue with the URLs that still have to be crawled.
+	queue := newCrawlQueue(slices.Clone(checkpoint.queue)...)
 
-		// Fetch the HTML data for the currentURL.
-		resp, err := client.Get(currentURL)
-		if err != nil {
-			crawler.errorLogger(fmt.Errorf("error fetching \"%s\": %w", currentURL, err))
-			continue
-		}
+	// Stop handing out URLs as soon as the context is cancelled.
+	stop := context.AfterFunc(ctx, queue.close)
+	defer stop()
 
-		// Ensure that the response was successful.
-		if resp.StatusCode != http.StatusOK {
-			crawler.errorLogger(fmt.Errorf("\"%s\" did not return status code 200: %d", currentURL, resp.StatusCode))
-			resp.Body.Close()
-			continue
-		}
+	// Create the HTTP client that is shared between all the workers. Every request it sends is
+	// counted against the request budget.
+	client := crawler.newHTTPClient()
+	client.Transport = &budgetTransport{crawler: crawler, base: client.Transport}
 
-		// Read the body of the response.
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			crawler.errorLogger(fmt.Errorf("error reading response body: %w", err))
-			resp.Body.Close()
-			continue
-		}
+	// Fetch the robots.txt rules before any pages are crawled. It's also needed for the sitemaps
+	// it links to.
+	var robots *robotsRules
+	if crawler.respectRobotsTxt || crawler.importLinkedSitemaps {
+		robots = crawler.fetchRobotsTxt(ctx, client)
+	}
 
-		// Since the body has been read into a variable we can safely close it.
-		resp.Body.Close()
+	crawler.robots = nil
+	if crawler.respectRobotsTxt {
+		crawler.robots = robots
+	}
+
+	// Space out the requests, using the larger of the configured delay and the
+	// delay requested by robots.txt.
+	crawlDelay := crawler.crawlDelay
+	if crawler.robots != nil {
+		crawlDelay = max(crawlDelay, crawler.robots.crawlDelay)
+	}
 
-		// Info log which site we are currently crawling.
-		crawler.infoLogger(fmt.Sprintf("Crawling '%s'", currentURL))
+	crawler.limiter = newRateLimiter(crawlDelay)
 
-		// Extract all the links from the page and add unvisited links to the queue.
-		links := crawler.extractLinks(bytes.NewReader(bodyBytes))
-		for _, link := range links {
-			if _, has := crawler.visited[link]; !has {
-				queue = append(queue, link)
-			}
+	// Queue the pages from the sitemaps that robots.txt links to, so that the pages that can't be
+	// reached by following links are crawled as well.
+	if crawler.importLinkedSitemaps && robots != nil {
+		for _, link := range crawler.importSitemaps(ctx, client, robots.sitemaps) {
+			queue.push(crawlItem{link: link, depth: 0})
 		}
+	}
 
-		// Compute a hash of the page content for change detection.
-		hasher := sha256.New()
-		hasher.Write(bodyBytes)
+	// Queue the URLs that were seeded since the previous crawl.
+	for _, link := range crawler.takeSeeds() {
+		queue.push(crawlItem{link: link, depth: 0})
+	}
 
-		// Store metadata for the current URL.
-		url := crawlerURL{
-			link:        currentURL,
-			checksum:    hex.EncodeToString(hasher.Sum(nil)),
-			lastChanged: time.Now(),
-		}
+	// Spin up the workers and wait for them to drain the queue.
+	concurrency := max(crawler.concurrency, 1)
+
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+
+	var processed atomic.Int64
+
+	for range concurrency {
+		go func() {
+			defer wg.Done()
 
-		crawler.visited[currentURL] = url
+			for {
+				item, ok := queue.pop()
+				if !ok {
+					return
+				}
+
+				// The queue is closed in the background once the crawl gets cancelled, so it can
+				// still hand out a URL, like one that was just put back below. It's left in the
+				// queue for the checkpoint since it was already reserved.
+				if ctx.Err() != nil {
+					queue.push(item)
+					queue.done(item)
+					return
+				}
+
+				// Skip the URL if another worker already took it.
+				if !crawler.reserve(item.link) {
+					queue.done(item)
+					continue
+				}
+
+				links := crawler.visit(ctx, client, item.link, item.referrer)
+
+				// Only enqueue the links that are still within the maximum depth.
+				if crawler.maxDepth <= 0 || item.depth < crawler.maxDepth {
+					items := make([]crawlItem, 0, len(links))
+					for _, link := range links {
+						items = append(items, crawlItem{link: link, depth: item.depth + 1, referrer: item.link})
+					}
+
+					queue.push(items...)
+				}
+
+				// A page that couldn't be fetched because the crawl got cancelled should still
+				// be crawled when the crawl is resumed.
+				if ctx.Err() != nil && !crawler.isVisited(item.link) {
+					queue.push(item)
+				}
+
+				queue.done(item)
+
+				if n := processed.Add(1); crawler.checkpointEvery > 0 && n%int64(crawler.checkpointEvery) == 0 {
+					crawler.saveCheckpoint(queue)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// Only keep a checkpoint around if the crawl didn't finish.
+	if ctx.Err() != nil && crawler.checkpointEvery > 0 {
+		crawler.saveCheckpoint(queue)
+	} else {
+		crawler.clearCheckpoint()
 	}
 
-	// Update the list of known links.
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	// Update the list of known links. If the crawl was cancelled we only have part of
+	// the site so we keep the links that we didn't get to.
 	newLinks := make(map[string]crawlerURL)
+	if ctx.Err() != nil {
+		maps.Copy(newLinks, crawler.links)
+	}
+
+	stats := CrawlStats{
+		PagesCrawled: len(crawler.visited),
+		Errors:       crawler.crawlErrors,
+	}
+
+	crawler.brokenLinks = slices.SortedFunc(maps.Values(crawler.broken), func(a, b BrokenLink) int {
+		return strings.Compare(a.URL, b.URL)
+	})
+
+	crawler.linkReferrers = make(map[string][]string, len(crawler.referrers))
+	for link, referrers := range crawler.referrers {
+		crawler.linkReferrers[link] = slices.Sorted(maps.Keys(referrers))
+	}
+
 	for linkVisited, urlVisited := range crawler.visited {
 		if oldUrl, has := crawler.links[linkVisited]; has {
+			urlVisited.manual = oldUrl.manual
+
 			if urlVisited.checksum != oldUrl.checksum {
 				newLinks[linkVisited] = urlVisited
+				stats.ChangedLinks++
+			} else if crawler.alwaysUpdateLastMod {
+				// Change detection is disabled so the page is stamped with the time of this
+				// crawl, even when it was reused after a 304 Not Modified response.
+				urlVisited.lastChanged = urlVisited.lastSeen
+				newLinks[linkVisited] = urlVisited
 			} else {
+				// The content hasn't changed but the server's Last-Modified header might have.
+				oldUrl.lastModified = urlVisited.lastModified
+				oldUrl.etag = urlVisited.etag
+				oldUrl.statusCode = urlVisited.statusCode
+				oldUrl.size = urlVisited.size
+				oldUrl.soft404 = urlVisited.soft404
+				oldUrl.lastSeen = urlVisited.lastSeen
+				oldUrl.outLinks = urlVisited.outLinks
+				oldUrl.externalLinks = urlVisited.externalLinks
+				oldUrl.crawlOrder = urlVisited.crawlOrder
 				newLinks[linkVisited] = oldUrl
 			}
 		} else {
 			newLinks[linkVisited] = urlVisited
+			stats.NewLinks++
+		}
+	}
+
+	// Pages that are gone for good shouldn't linger in the sitemap, even when the crawl was
+	// cancelled and the links it didn't get to are kept.
+	for link, broken := range crawler.broken {
+		if broken.StatusCode == http.StatusNotFound || broken.StatusCode == http.StatusGone {
+			delete(newLinks, link)
 		}
 	}
 
-	crawler.links = newLinks
+	// The links that were added by hand stay until they're removed.
+	for link, url := range crawler.links {
+		if _, has := newLinks[link]; url.manual && !has {
+			newLinks[link] = url
+		}
+	}
+
+	crawlErr := context.Cause(ctx)
+	if errors.Is(crawlErr, ErrRequestBudgetExhausted) {
+		crawler.errorLogger(crawlErr)
+	}
+
+	// A crawl that lost most of the site is more likely to have gone wrong than the site is to
+	// have shrunk, so the previous links are better than the ones it found.
+	if found, known := crawler.knownLinksFound(newLinks); crawlErr == nil && crawler.fallbackThreshold > 0 &&
+		known > 0 && float64(found)*100 < crawler.fallbackThreshold*float64(known) {
+		crawlErr = fmt.Errorf("%w: found %d of the %d known pages, keeping the previous links", ErrBelowFallbackThreshold, found, known)
+		crawler.errorLogger(crawlErr)
+	} else {
+		crawler.links = newLinks
+	}
+
+	stats.LastCrawlTime = time.Now()
+	stats.Duration = stats.LastCrawlTime.Sub(start)
+	crawler.stats = stats
+
+	if crawlErr == nil {
+		crawler.lastCrawlTime = stats.LastCrawlTime
+	}
+
+	crawler.metrics.ObserveCrawlDuration(stats.Duration)
+	crawler.metrics.SetKnownLinks(len(crawler.links))
+	crawler.metrics.SetLastCrawlTime(stats.LastCrawlTime)
+
+	crawler.emit(CrawlEvent{Type: CrawlFinished, URL: normalizedURL, Err: crawlErr})
+
+	return crawlErr
 }
 
-// getLinks retrieves all discovered links as a slice of crawlerURL.
-func (crawler *crawler) getLinks() []crawlerURL {
+// knownLinksFound counts how many of the known links, leaving out the ones that were added by
+// hand, are in links. It also returns the number of known links it compared against.
+func (crawler *crawler) knownLinksFound(links map[string]crawlerURL) (found int, known int) {
+	for link, url := range crawler.links {
+		if url.manual {
+			continue
+		}
+
+		known++
+		if _, has := links[link]; has {
+			found++
+		}
+	}
+
+	return found, known
+}
+
+// newHTTPClient creates the HTTP client used during a crawl. It's based on the user supplied
+// client if there is one, otherwise a new client is created.
+//
+// The client will error on redirects unless the crawler has been configured to follow them or
+// the user supplied client has its own CheckRedirect.
+func (crawler *crawler) newHTTPClient() *http.Client {
+	client := &http.Client{}
+	if crawler.httpClient != nil {
+		// Work on a copy so that the user's client isn't modified.
+		*client = *crawler.httpClient
+	}
+
+	if client.CheckRedirect == nil {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if !crawler.followRedirects {
+				return errors.New("redirects not allowed")
+			}
+
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+
+			// Don't follow redirects that lead outside of the domain.
+			if _, ok := crawler.normalizeURL(req.URL.String()); !ok {
+				return http.ErrUseLastResponse
+			}
+
+			return nil
+		}
+	}
+
+	if crawler.requestTimeout > 0 {
+		client.Timeout = crawler.requestTimeout
+	}
+
+	// Send the requests through the proxy, without modifying the user's transport.
+	if crawler.proxyURL != nil {
+		transport := client.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+
+		if transport, ok := transport.(*http.Transport); ok {
+			transport = transport.Clone()
+			transport.Proxy = http.ProxyURL(crawler.proxyURL)
+			client.Transport = transport
+		}
+	}
+
+	// Keep track of cookies so that sessions carry through the crawl. A jar is only created
+	// for the crawl when cookies have to be sent and nothing else supplied one.
+	if crawler.cookieJar != nil {
+		client.Jar = crawler.cookieJar
+	} else if client.Jar == nil && len(crawler.cookies) > 0 {
+		client.Jar, _ = cookiejar.New(nil)
+	}
+
+	if client.Jar != nil && len(crawler.cookies) > 0 {
+		if domainURL, err := url.Parse(crawler.domain); err == nil {
+			client.Jar.SetCookies(domainURL, crawler.cookies)
+		}
+	}
+
+	return client
+}
+
+// newRequest creates a GET request for the given URL with all the headers the crawler
+// should send.
+func (crawler *crawler) newRequest(ctx context.Context, link string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only hand the custom headers and credentials to the site being crawled, never to anyone else.
+	_, inDomain := crawler.normalizeURL(link)
+
+	if inDomain {
+		for key, values := range crawler.requestHeaders {
+			req.Header[key] = slices.Clone(values)
+		}
+	}
+
+	userAgent := crawler.userAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+
+	if inDomain && crawler.basicAuthUsername != "" {
+		req.SetBasicAuth(crawler.basicAuthUsername, crawler.basicAuthPassword)
+	}
+
+	return req, nil
+}
+
+// visit fetches a single URL, records it as visited and returns the unvisited links
+// that were found on the page. The referrer is the page the URL was found on, which is
+// reported along with the URL if it turns out to be broken. The URL has to have been reserved
+// with reserve first.
+func (crawler *crawler) visit(ctx context.Context, client *http.Client, currentURL string, referrer string) []string {
+	// Skip the URL if robots.txt doesn't allow us to crawl it.
+	if !crawler.robots.allowed(currentURL) {
+		crawler.infoLogger(fmt.Sprintf("Skipping '%s' as it is disallowed by robots.txt", currentURL))
+		return nil
+	}
+
+	// Wait until we're allowed to make the next request.
+	if err := crawler.limiter.wait(ctx); err != nil {
+		return nil
+	}
+
+	// Fetch the HTML data for the currentURL.
+	req, err := crawler.newRequest(ctx, currentURL)
+	if err != nil {
+		crawler.logCrawlError(currentURL, fmt.Errorf("error creating request for \"%s\": %w", currentURL, err))
+		return nil
+	}
+
+	// Ask the server to only send the page if it changed since the previous crawl.
+	known, conditional := crawler.setConditionalHeaders(req, currentURL)
+
+	resp, err := crawler.fetch(client, req)
+	if err != nil {
+		// Requests that were aborted because the crawl got cancelled aren't errors.
+		if ctx.Err() == nil {
+			crawler.recordBrokenLink(currentURL, referrer, 0)
+			crawler.logCrawlError(currentURL, fmt.Errorf("error fetching \"%s\": %w", currentURL, err))
+		}
+
+		return nil
+	}
+
+	// The page hasn't changed so there is no body to read. It's recorded the way it was found
+	// during the previous crawl.
+	if conditional && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		crawler.infoLogger(fmt.Sprintf("Crawling '%s' (not modified)", currentURL))
+
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			known.etag = etag
+		}
+
+		if lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+			known.lastModified = lastModified
+		}
+
+		known.statusCode = resp.StatusCode
+		known.lastSeen = time.Now()
+		return crawler.recordPage(known, nil)
+	}
+
+	// Redirects are only handed back to us when they lead outside of the domain.
+	if crawler.followRedirects && resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		crawler.infoLogger(fmt.Sprintf("Skipping '%s' as it redirects outside of the domain", currentURL))
+		resp.Body.Close()
+		return nil
+	}
+
+	// Ensure that the response was successful.
+	if resp.StatusCode != http.StatusOK {
+		crawler.recordBrokenLink(currentURL, referrer, resp.StatusCode)
+		crawler.logCrawlError(currentURL, fmt.Errorf("\"%s\" did not return status code 200: %d", currentURL, resp.StatusCode))
+		resp.Body.Close()
+		return nil
+	}
+
+	// Skip responses that aren't pages, like PDFs or images, without reading them.
+	if !crawler.isAllowedContentType(resp.Header.Get("Content-Type")) {
+		crawler.infoLogger(fmt.Sprintf("Skipping '%s' as its content type '%s' is not allowed", currentURL, resp.Header.Get("Content-Type")))
+		resp.Body.Close()
+		return nil
+	}
+
+	// If the request got redirected the page should be recorded under the URL we ended up on.
+	if finalURL := resp.Request.URL.String(); finalURL != currentURL {
+		normalizedURL, ok := crawler.normalizeURL(finalURL)
+		if !ok {
+			crawler.infoLogger(fmt.Sprintf("Skipping '%s' as it redirects outside of the domain", currentURL))
+			resp.Body.Close()
+			return nil
+		}
+
+		currentURL = normalizedURL
+	}
+
+	// Info log which site we are currently crawling.
+	crawler.infoLogger(fmt.Sprintf("Crawling '%s'", currentURL))
+
+	page, body, err := crawler.readPage(resp)
+	resp.Body.Close()
+
+	if err != nil {
+		if ctx.Err() == nil {
+			crawler.logCrawlError(currentURL, fmt.Errorf("error reading response body of \"%s\": %w", currentURL, err))
+		}
+
+		return nil
+	}
+
+	// The X-Robots-Tag header works just like a robots meta tag.
+	if directives := robotsHeaderDirectives(resp.Header.Values("X-Robots-Tag")); len(directives) > 0 {
+		if slices.Contains(directives, "noindex") {
+			page.noindex = true
+		}
+
+		if slices.Contains(directives, "nofollow") {
+			page.nofollow = true
+
+			if crawler.respectNofollow {
+				page.links = []string{}
+				page.externalLinks = nil
+			}
+		}
+	}
+
+	// The links found by a custom link extractor replace the ones the tokenizer found, unless the
+	// page asked for its links not to be followed.
+	if crawler.linkExtractor != nil && !(crawler.respectNofollow && page.nofollow) {
+		if links, externalLinks, ok := crawler.extractorLinks(currentURL, body.decoded); ok {
+			page.links = links
+			page.externalLinks = externalLinks
+		}
+	}
+
+	// Pages that say they weren't found are still crawled for their links.
+	soft404 := crawler.soft404Pattern != nil && crawler.soft404Pattern.Match(body.decoded)
+	if soft404 {
+		crawler.infoLogger(fmt.Sprintf("Leaving '%s' out of the sitemap as it looks like a soft 404", currentURL))
+	}
+
+	// Record the page under the canonical URL it declares, as long as it's within the domain.
+	if crawler.respectCanonical && page.canonical != "" {
+		currentURL = page.canonical
+	}
+
+	// Store metadata for the current URL.
+	url := crawlerURL{
+		link:          currentURL,
+		checksum:      body.checksum,
+		lastChanged:   time.Now(),
+		lastSeen:      time.Now(),
+		etag:          resp.Header.Get("ETag"),
+		statusCode:    resp.StatusCode,
+		size:          body.size,
+		noindex:       page.noindex,
+		soft404:       soft404,
+		images:        page.images,
+		alternates:    page.alternates,
+		videos:        page.videos,
+		outLinks:      page.links,
+		externalLinks: page.externalLinks,
+	}
+
+	// Use the server's Last-Modified header if it sent a valid one.
+	if lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		url.lastModified = lastModified
+	}
+
+	return crawler.recordPage(url, body.decoded)
+}
+
+// fetch sends the request with the client, or hands its URL to the page fetcher if there is one.
+// The HTML the page fetcher returns is wrapped in a successful response so that it's handled
+// exactly like a page that was fetched with the client.
+func (crawler *crawler) fetch(client *http.Client, req *http.Request) (*http.Response, error) {
+	if crawler.pageFetcher == nil {
+		return client.Do(req)
+	}
+
+	if !crawler.useRequest() {
+		return nil, ErrRequestBudgetExhausted
+	}
+
+	body, err := crawler.pageFetcher(req.Context(), req.URL.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// reserve marks the URL as taken by a worker. It returns false if the URL has already been
+// reserved or visited during the current crawl, in which case it shouldn't be fetched again.
+func (crawler *crawler) reserve(link string) bool {
 	crawler.mutex.Lock()
 	defer crawler.mutex.Unlock()
 
-	return slices.Collect(maps.Values(crawler.links))
+	_, visited := crawler.visited[link]
+	_, reserved := crawler.reserved[link]
+	if visited || reserved {
+		return false
+	}
+
+	crawler.reserved[link] = struct{}{}
+
+	return true
 }
 
-// extractLinks parses HTML content and extracts links based on the specified attributes.
-func (crawler *crawler) extractLinks(r io.Reader) []string {
-	links := []string{}
-	tokenizer := html.NewTokenizer(r)
+// recordVisit records the page as visited and returns the links on it that haven't been visited
+// yet and that should be crawled.
+// It returns false if the page had already been visited, in which case it isn't recorded again.
+func (crawler *crawler) recordVisit(url crawlerURL) ([]string, bool) {
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
 
-	for {
-		tt := tokenizer.Next()
+	// A redirect or canonical URL could have led us to a page that has already been visited.
+	if _, has := crawler.visited[url.link]; has {
+		return nil, false
+	}
 
-		switch tt {
-		case html.StartTagToken, html.SelfClosingTagToken:
-			token := tokenizer.Token()
+	url.crawlOrder = len(crawler.visited)
+	crawler.visited[url.link] = url
+	crawler.emit(CrawlEvent{Type: PageFetched, URL: url.link})
+	crawler.metrics.IncPagesCrawled()
 
-			// Handle <a> tags specifically. This is necessary because <link> tags also use
-			// href attributes and there is no reason why we'd ever want to crawl a <link>.
-			if token.Data == "a" {
-				for _, attr := range token.Attr {
-					if attr.Key == "href" {
-						link := attr.Val
-						if normalized, ok := crawler.normalizeURL(link); ok {
-							links = append(links, normalized)
-						}
-					}
-				}
-			} else {
-				// Handle the other tags based on what the user has configured.
-				for _, attr := range token.Attr {
-					if slices.Contains(crawler.linkAttributes, attr.Key) {
-						link := attr.Val
-						if normalized, ok := crawler.normalizeURL(link); ok {
-							links = append(links, normalized)
-						}
-					}
-				}
-			}
-		case html.ErrorToken:
-			// End of the document or an error.
-			return links
+	// External links are only recorded for the link graph, they're never crawled.
+	for _, link := range url.externalLinks {
+		crawler.recordReferrer(link, url.link)
+	}
+
+	// Only hand back the links that haven't been visited yet and that should be crawled.
+	unvisited := []string{}
+	for _, link := range url.outLinks {
+		crawler.recordReferrer(link, url.link)
+
+		_, visited := crawler.visited[link]
+		_, reserved := crawler.reserved[link]
+
+		if !visited && !reserved && crawler.shouldCrawl(link) {
+			unvisited = append(unvisited, link)
 		}
 	}
+
+	return unvisited, true
+}
+
+// recordPage records the visit of the page and hands it to the page callback once it has been
+// recorded. The callback is called without holding the mutex, so that it doesn't hold up the
+// other workers.
+func (crawler *crawler) recordPage(url crawlerURL, body []byte) []string {
+	links, recorded := crawler.recordVisit(url)
+	if recorded && crawler.pageCallback != nil {
+		crawler.pageCallback(url.link, url.statusCode, body)
+	}
+
+	return links
+}
+
+// setConditionalHeaders adds the If-None-Match and If-Modified-Since headers to the request, based
+// on what the server sent for the page during the previous crawl. It returns the known page and
+// whether any headers were added. Pages whose links aren't known are always fetched in full.
+func (crawler *crawler) setConditionalHeaders(req *http.Request, link string) (crawlerURL, bool) {
+	if !crawler.useConditionalRequests {
+		return crawlerURL{}, false
+	}
+
+	crawler.mutex.Lock()
+	known, has := crawler.links[link]
+	crawler.mutex.Unlock()
+
+	if !has || known.outLinks == nil {
+		return crawlerURL{}, false
+	}
+
+	conditional := false
+
+	if known.etag != "" {
+		req.Header.Set("If-None-Match", known.etag)
+		conditional = true
+	}
+
+	if !known.lastModified.IsZero() {
+		req.Header.Set("If-Modified-Since", known.lastModified.UTC().Format(http.TimeFormat))
+		conditional = true
+	}
+
+	return known, conditional
+}
+
+// isAllowedContentType checks whether the media type of the Content-Type header matches any of
+// the allowed content types. Responses without a Content-Type are allowed.
+func (crawler *crawler) isAllowedContentType(contentType string) bool {
+	if strings.TrimSpace(contentType) == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, pattern := range crawler.allowedContentTypes {
+		if matched, _ := path.Match(pattern, mediaType); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pageBody is what's left of the body of a page once it has been streamed through the tokenizer.
+type pageBody struct {
+	// checksum is the hex encoded SHA-256 hash of the body, or of its visible text depending on the
+	// change detection mode, used to detect changes between crawls.
+	checksum string
+
+	// size is the size of the body in bytes, after it has been decompressed.
+	size int
+
+	// decoded is the body decoded to UTF-8. It's only kept when the crawler has a soft 404
+	// pattern to match against it or a link extractor or page callback to hand it to.
+	decoded []byte
+}
+
+// readPage streams the body of the response through the hasher and the tokenizer at the same
+// time, so that the page never has to be held in memory as a whole. The body is decompressed if
+// needed and decoded to UTF-8 before it's parsed, so that the links on pages in other charsets
+// are read correctly, while the checksum of the raw HTML covers the body before it was decoded.
+//
+// An error is returned if the body couldn't be read or if it's larger than the maximum response
+// size, in which case the page shouldn't be recorded.
+func (crawler *crawler) readPage(resp *http.Response) (pageInfo, pageBody, error) {
+	rawBody, err := openBody(resp)
+	if err != nil {
+		return pageInfo{}, pageBody{}, err
+	}
+
+	hasher := sha256.New()
+	var size byteCounter
+
+	// Read one byte more than allowed to find out whether the body is too large.
+	limited := io.LimitReader(rawBody, crawler.maxResponseBytes+1)
+	decoder, err := utf8Reader(io.TeeReader(limited, io.MultiWriter(hasher, &size)), resp.Header.Get("Content-Type"))
+	if err != nil {
+		return pageInfo{}, pageBody{}, err
+	}
+
+	body := &errorRecorder{r: decoder}
+
+	// The soft 404 pattern has to be matched against the whole page and the link extractor and
+	// the page callback need the whole page too, so it's only kept when any of them is set.
+	var decoded bytes.Buffer
+	pageReader := io.Reader(body)
+	if crawler.soft404Pattern != nil || crawler.linkExtractor != nil || crawler.pageCallback != nil {
+		pageReader = io.TeeReader(body, &decoded)
+	}
+
+	page := crawler.parsePage(pageReader)
+
+	// The tokenizer stops at the first error so whatever it didn't read still has to be hashed.
+	io.Copy(io.Discard, pageReader)
+
+	if body.err != nil {
+		return pageInfo{}, pageBody{}, body.err
+	}
+
+	if int64(size) > crawler.maxResponseBytes {
+		return pageInfo{}, pageBody{}, fmt.Errorf("body is larger than %d bytes", crawler.maxResponseBytes)
+	}
+
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	if page.checksum != "" {
+		checksum = page.checksum
+	}
+
+	return page, pageBody{
+		checksum: checksum,
+		size:     int(size),
+		decoded:  decoded.Bytes(),
+	}, nil
+}
+
+// byteCounter is a writer that counts the bytes written to it.
+type byteCounter int64
+
+// Write counts the bytes in p.
+func (counter *byteCounter) Write(p []byte) (int, error) {
+	*counter += byteCounter(len(p))
+	return len(p), nil
+}
+
+// errorRecorder is a reader that remembers the first error other than io.EOF that reading from
+// r returned. The tokenizer treats every error as the end of the page without returning it.
+type errorRecorder struct {
+	r   io.Reader
+	err error
+}
+
+// Read reads from the underlying reader and records its error.
+func (recorder *errorRecorder) Read(p []byte) (int, error) {
+	n, err := recorder.r.Read(p)
+	if err != nil && err != io.EOF && recorder.err == nil {
+		recorder.err = err
+	}
+
+	return n, err
+}
+
+// openBody returns a reader for the body of the response. Go's HTTP transport asks for gzip and
+// decompresses the response transparently, but some servers send gzip even when it wasn't asked
+// for, like when a custom transport is used or compression is disabled. Those bodies are
+// decompressed here so that they can be parsed and hashed.
+func openBody(resp *http.Response) (io.Reader, error) {
+	if !resp.Uncompressed && strings.EqualFold(strings.TrimSpace(resp.Header.Get("Content-Encoding")), "gzip") {
+		gzipReader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress gzip body: %w", err)
+		}
+
+		return gzipReader, nil
+	}
+
+	return resp.Body, nil
+}
+
+// readBody reads the entire body of the response, decompressing it if needed.
+//
+// An error is returned if the (decompressed) body is larger than maxBytes, so that a single
+// huge response can't exhaust the memory.
+func readBody(resp *http.Response, maxBytes int64) ([]byte, error) {
+	body, err := openBody(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read one byte more than allowed to find out whether the body is too large.
+	bodyBytes, err := io.ReadAll(io.LimitReader(body, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(bodyBytes)) > maxBytes {
+		return nil, fmt.Errorf("body is larger than %d bytes", maxBytes)
+	}
+
+	return bodyBytes, nil
+}
+
+// shouldCrawl checks the scope, include and exclude patterns to determine whether a discovered
+// URL should be crawled. The exclude patterns take precedence over the include patterns.
+func (crawler *crawler) shouldCrawl(link string) bool {
+	if !crawler.inScope(link) {
+		return false
+	}
+
+	for _, pattern := range crawler.excludePatterns {
+		if pattern.MatchString(link) {
+			return false
+		}
+	}
+
+	if len(crawler.includePatterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range crawler.includePatterns {
+		if pattern.MatchString(link) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// inScope checks whether the path of the URL matches any of the scope patterns.
+func (crawler *crawler) inScope(link string) bool {
+	if len(crawler.scopePatterns) == 0 {
+		return true
+	}
+
+	parsedURL, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+
+	path := parsedURL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	for _, pattern := range crawler.scopePatterns {
+		if pattern.MatchString(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getLinks retrieves all discovered links as a slice of crawlerURL.
+func (crawler *crawler) getLinks() []crawlerURL {
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	return slices.Collect(maps.Values(crawler.links))
 }
 
 // normalizeURL normalizes a URL and ensures it belongs to the specified domain.
 func (crawler *crawler) normalizeURL(href string) (string, bool) {
+	return crawler.normalizeURLAgainst(href, nil)
+}
+
+// normalizeURLAgainst normalizes the URL the same way normalizeURL does, except that relative
+// URLs are resolved against base, like the <base href> of a page. The domain is used when base
+// is nil.
+func (crawler *crawler) normalizeURLAgainst(href string, base *url.URL) (string, bool) {
 	// Explicitly handle empty strings
 	if strings.TrimSpace(href) == "" {
 		return "", false
@@ -235,28 +1289,220 @@ func (crawler *crawler) normalizeURL(href string) (string, bool) {
 		return "", false
 	}
 
-	// Resolve relative URLs against the base domain.
+	// Resolve relative URLs against the base URL or the base domain.
 	if !parsedURL.IsAbs() {
-		baseURL, err := url.Parse(crawler.domain)
-		if err != nil {
-			return "", false
+		baseURL := base
+		if baseURL == nil {
+			if baseURL, err = url.Parse(crawler.domain); err != nil {
+				return "", false
+			}
 		}
 		parsedURL = baseURL.ResolveReference(parsedURL)
 	}
 
-	// Remove URL fragments and trailing slashes.
+	// The scheme and host are case-insensitive, unlike the path.
+	parsedURL.Scheme = strings.ToLower(parsedURL.Scheme)
+	parsedURL.Host = strings.ToLower(parsedURL.Host)
+
+	// "http://example.com:80" and "http://example.com" are the same page.
+	stripDefaultPort(parsedURL)
+
+	// Rewrite links with the other web scheme to the domain's scheme if that's the same site.
+	if domainURL, err := url.Parse(crawler.domain); err == nil {
+		parsedURL = crawler.withDomainScheme(parsedURL, domainURL)
+	}
+
+	// Remove the parts of the path that would make the same page show up under many URLs.
+	if collapsed := crawler.collapsePath(parsedURL.Path); collapsed != parsedURL.Path {
+		parsedURL.Path = collapsed
+		parsedURL.RawPath = ""
+	}
+
+	// Remove the entire query string or just the parameters that should be ignored.
+	if crawler.stripQueryParams {
+		parsedURL.RawQuery = ""
+		parsedURL.ForceQuery = false
+	} else if len(crawler.ignoreQueryParams) > 0 && parsedURL.RawQuery != "" {
+		query := parsedURL.Query()
+		for key := range query {
+			if crawler.isIgnoredQueryParam(key) {
+				query.Del(key)
+			}
+		}
+
+		parsedURL.RawQuery = query.Encode()
+	}
+
+	// Remove URL fragments and apply the trailing slash policy.
 	parsedURL.Fragment = ""
-	normalized := strings.TrimRight(parsedURL.String(), "/")
+	normalized := crawler.applyTrailingSlashPolicy(parsedURL)
 
 	// Ensure the URL belongs to the specified domain.
-	if strings.HasPrefix(normalized, crawler.domain) {
+	if crawler.inDomain(normalized) {
 		return normalized, true
 	}
 
 	return "", false
 }
 
-// ensureTrailingSlash appends a trailing slash to URLs without file extensions or paths.
+// inDomain checks whether the absolute URL belongs to the domain, or to one of its subdomains
+// when those are allowed. The scheme and host are compared rather than the text of the URLs, so
+// "http://example.com.evil.com" isn't part of "http://example.com" while "http://example.com:80"
+// is.
+func (crawler *crawler) inDomain(link string) bool {
+	linkURL, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+
+	domainURL, err := url.Parse(crawler.domain)
+	if err != nil {
+		return false
+	}
+
+	linkURL = crawler.withDomainScheme(linkURL, domainURL)
+	if !strings.EqualFold(linkURL.Scheme, domainURL.Scheme) {
+		return false
+	}
+
+	if hostPort(linkURL) == hostPort(domainURL) {
+		return true
+	}
+
+	if !crawler.allowSubdomains {
+		return false
+	}
+
+	// IP addresses don't have subdomains.
+	domainHost := domainURL.Hostname()
+	if net.ParseIP(domainHost) != nil {
+		return false
+	}
+
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(domainHost)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(linkURL.Hostname())
+	return host == registrable || strings.HasSuffix(host, "."+registrable)
+}
+
+// withDomainScheme returns a copy of the URL with the scheme of the domain when the crawler ignores
+// the scheme and both are either "http" or "https". An explicit default port of the URL's scheme
+// is dropped along with it. Otherwise the URL is returned as is.
+func (crawler *crawler) withDomainScheme(u *url.URL, domainURL *url.URL) *url.URL {
+	scheme := strings.ToLower(u.Scheme)
+	domainScheme := strings.ToLower(domainURL.Scheme)
+
+	if !crawler.ignoreScheme || scheme == domainScheme || !isWebScheme(scheme) || !isWebScheme(domainScheme) {
+		return u
+	}
+
+	rewritten := *u
+	stripDefaultPort(&rewritten)
+	rewritten.Scheme = domainScheme
+
+	return &rewritten
+}
+
+// stripDefaultPort removes the port from the URL if it's the default port of its scheme, which is
+// 80 for "http" and 443 for "https". Other ports are kept.
+func stripDefaultPort(u *url.URL) {
+	scheme := strings.ToLower(u.Scheme)
+	if port := u.Port(); (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+}
+
+// isWebScheme checks whether the scheme is "http" or "https".
+func isWebScheme(scheme string) bool {
+	return scheme == "http" || scheme == "https"
+}
+
+// hostPort returns the lowercased host of the URL along with its port, using the default port of
+// the scheme if the URL doesn't have one.
+func hostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch strings.ToLower(u.Scheme) {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+
+	return net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+// collapsePath removes the parts of the path that are matched by the capture groups of the
+// crawler's path collapse patterns.
+func (crawler *crawler) collapsePath(path string) string {
+	for _, pattern := range crawler.pathCollapsePatterns {
+		matches := pattern.FindAllStringSubmatchIndex(path, -1)
+		if matches == nil {
+			continue
+		}
+
+		var builder strings.Builder
+		last := 0
+
+		for _, match := range matches {
+			// The first pair of indexes is the whole match, the others are the capture groups.
+			// Groups that didn't participate are -1, and nested groups are covered by their parent.
+			for i := 2; i < len(match); i += 2 {
+				start, end := match[i], match[i+1]
+				if start < last {
+					continue
+				}
+
+				builder.WriteString(path[last:start])
+				last = end
+			}
+		}
+
+		builder.WriteString(path[last:])
+		path = builder.String()
+	}
+
+	return path
+}
+
+// applyTrailingSlashPolicy adds or removes the trailing slash of the URL's path, depending on
+// the crawler's policy, so that the same page is always recorded under the same URL.
+func (crawler *crawler) applyTrailingSlashPolicy(parsedURL *url.URL) string {
+	switch crawler.trailingSlashPolicy {
+	case TrailingSlashAdd:
+		return ensureTrailingSlash(parsedURL.String())
+	case TrailingSlashPreserve:
+		// The root of the site is the same page with or without the slash.
+		if parsedURL.Path == "" {
+			parsedURL.Path = "/"
+		}
+
+		return parsedURL.String()
+	default:
+		parsedURL.Path = strings.TrimRight(parsedURL.Path, "/")
+		parsedURL.RawPath = strings.TrimRight(parsedURL.RawPath, "/")
+
+		return parsedURL.String()
+	}
+}
+
+// isIgnoredQueryParam checks whether the query parameter matches any of the ignored parameters.
+func (crawler *crawler) isIgnoredQueryParam(key string) bool {
+	for _, pattern := range crawler.ignoreQueryParams {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ensureTrailingSlash appends a trailing slash to URLs without paths or whose last path segment
+// doesn't have a file extension.
 func ensureTrailingSlash(urlStr string) string {
 	parsedURL, err := url.Parse(urlStr)
 	if err != nil {
@@ -264,8 +1510,11 @@ func ensureTrailingSlash(urlStr string) string {
 	}
 
 	if parsedURL.Path == "" || !strings.HasSuffix(parsedURL.Path, "/") {
-		if !strings.Contains(parsedURL.Path, ".") {
+		if path.Ext(parsedURL.Path) == "" {
 			parsedURL.Path += "/"
+			if parsedURL.RawPath != "" {
+				parsedURL.RawPath += "/"
+			}
 		}
 	}
 
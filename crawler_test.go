@@ -2,8 +2,22 @@ package sitemapper
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
 	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestExtractLinks(t *testing.T) {
@@ -63,6 +77,7 @@ func TestNormalizeURL(t *testing.T) {
 		{"javascript:void(0)", "", false},
 		{"http://otherdomain.com", "", false},
 		{"http://example.com/page#fragment", "http://example.com/page", true},
+		{"HTTP://Example.COM/Page", "http://example.com/Page", true},
 		{"", "", false},
 	}
 
@@ -77,6 +92,201 @@ func TestNormalizeURL(t *testing.T) {
 	}
 }
 
+func TestNormalizeURLHostComparison(t *testing.T) {
+	tests := []struct {
+		domain   string
+		input    string
+		expected bool
+	}{
+		{"http://example.com", "http://example.com/page", true},
+		{"http://example.com", "http://example.com:80/page", true},
+		{"http://example.com", "HTTP://EXAMPLE.COM/page", true},
+		{"https://example.com", "https://example.com:443/page", true},
+		{"http://example.com", "http://example.com.evil.com/page", false},
+		{"http://example.com", "http://example.com:8080/page", false},
+		{"http://example.com", "https://example.com/page", false},
+		{"http://localhost:8080", "http://localhost:8080/page", true},
+		{"http://localhost:8080", "http://localhost:80801/page", false},
+		{"http://localhost:8080", "http://localhost/page", false},
+	}
+
+	for _, test := range tests {
+		c := newCrawler(test.domain, nil, nil, nil)
+
+		if _, ok := c.normalizeURL(test.input); ok != test.expected {
+			t.Errorf("Expected '%s' to be in the domain of '%s': %v, got %v", test.input, test.domain, test.expected, ok)
+		}
+	}
+}
+
+func TestNormalizeURLQueryParams(t *testing.T) {
+	c := newCrawler("http://example.com", nil, nil, nil)
+	c.ignoreQueryParams = []string{"utm_*", "sid"}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"/products?sort=price&page=2", "http://example.com/products?page=2&sort=price"},
+		{"/products?utm_source=news&utm_medium=email", "http://example.com/products"},
+		{"/products?sid=abc&page=2", "http://example.com/products?page=2"},
+		{"/products", "http://example.com/products"},
+	}
+
+	for _, test := range tests {
+		if normalized, _ := c.normalizeURL(test.input); normalized != test.expected {
+			t.Errorf("Expected normalized URL '%s' for input '%s', got '%s'", test.expected, test.input, normalized)
+		}
+	}
+
+	c.stripQueryParams = true
+
+	if normalized, _ := c.normalizeURL("/products?sort=price&page=2"); normalized != "http://example.com/products" {
+		t.Errorf("Expected the query string to be stripped, got '%s'", normalized)
+	}
+}
+
+func TestNormalizeURLPathPatternsToCollapse(t *testing.T) {
+	c := newCrawler("http://example.com", nil, nil, nil)
+	c.pathCollapsePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`/s/([^/]+/)`),
+		regexp.MustCompile(`(;jsessionid=[^/]*)`),
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"/s/abc123/page", "http://example.com/s/page"},
+		{"/s/xyz789/page", "http://example.com/s/page"},
+		{"/s/abc123/docs/s/def456/intro", "http://example.com/s/docs/s/intro"},
+		{"/cart;jsessionid=0AB1C2", "http://example.com/cart"},
+		{"/about", "http://example.com/about"},
+	}
+
+	for _, test := range tests {
+		if normalized, _ := c.normalizeURL(test.input); normalized != test.expected {
+			t.Errorf("Expected normalized URL '%s' for input '%s', got '%s'", test.expected, test.input, normalized)
+		}
+	}
+}
+
+func TestNormalizeURLAllowSubdomains(t *testing.T) {
+	tests := []struct {
+		domain   string
+		input    string
+		expected bool
+	}{
+		{"https://www.example.com", "https://blog.example.com/post", true},
+		{"https://www.example.com", "https://example.com/", true},
+		{"https://www.example.com", "https://a.b.example.com/", true},
+		{"https://www.example.com", "http://blog.example.com/", false},
+		{"https://www.example.com", "https://example.org/", false},
+		{"https://www.example.com", "https://notexample.com/", false},
+		{"https://shop.example.co.uk", "https://blog.example.co.uk/", true},
+		{"https://shop.example.co.uk", "https://other.co.uk/", false},
+		{"http://localhost:8080", "http://sub.localhost:8080/", false},
+		{"http://127.0.0.1:8080", "http://0.0.1:8080/", false},
+	}
+
+	for _, test := range tests {
+		c := newCrawler(test.domain, nil, nil, nil)
+
+		if _, ok := c.normalizeURL(test.input); ok {
+			t.Errorf("Expected '%s' to be outside of '%s' when subdomains aren't allowed", test.input, test.domain)
+		}
+
+		c.allowSubdomains = true
+
+		if _, ok := c.normalizeURL(test.input); ok != test.expected {
+			t.Errorf("Expected '%s' to be in the domain of '%s': %v, got %v", test.input, test.domain, test.expected, ok)
+		}
+	}
+}
+
+func TestNormalizeURLDefaultPorts(t *testing.T) {
+	tests := []struct {
+		domain   string
+		input    string
+		expected string
+	}{
+		{"http://example.com", "http://example.com:80/about", "http://example.com/about"},
+		{"http://example.com", "http://EXAMPLE.com:80", "http://example.com"},
+		{"https://example.com", "https://example.com:443/about?page=2", "https://example.com/about?page=2"},
+		{"https://example.com", "https://example.com:80/about", ""},
+		{"http://example.com:8080", "http://example.com:8080/about", "http://example.com:8080/about"},
+		{"http://[::1]", "http://[::1]:80/about", "http://[::1]/about"},
+		{"https://example.com:443", "/about", "https://example.com/about"},
+	}
+
+	for _, test := range tests {
+		c := newCrawler(test.domain, nil, nil, nil)
+
+		if normalized, _ := c.normalizeURL(test.input); normalized != test.expected {
+			t.Errorf("normalizeURL(%q) = %q, want %q", test.input, normalized, test.expected)
+		}
+	}
+}
+
+func TestNormalizeURLIgnoreScheme(t *testing.T) {
+	tests := []struct {
+		domain   string
+		input    string
+		expected string
+	}{
+		{"https://example.com", "http://example.com/about", "https://example.com/about"},
+		{"https://example.com", "HTTP://example.com:80/about", "https://example.com/about"},
+		{"https://example.com", "https://example.com/about", "https://example.com/about"},
+		{"http://example.com", "https://example.com/about", "http://example.com/about"},
+		{"https://example.com", "http://example.com:8080/about", ""},
+		{"https://example.com", "http://example.org/about", ""},
+		{"https://example.com", "ftp://example.com/about", ""},
+	}
+
+	for _, test := range tests {
+		c := newCrawler(test.domain, nil, nil, nil)
+
+		if normalized, ok := c.normalizeURL(test.input); ok && normalized != test.input {
+			t.Errorf("Expected '%s' to be left alone when the scheme isn't ignored, got '%s'", test.input, normalized)
+		}
+
+		c.ignoreScheme = true
+
+		if normalized, _ := c.normalizeURL(test.input); normalized != test.expected {
+			t.Errorf("normalizeURL(%q) = %q, want %q", test.input, normalized, test.expected)
+		}
+	}
+}
+
+func TestNormalizeURLTrailingSlashPolicy(t *testing.T) {
+	c := newCrawler("http://example.com", nil, nil, nil)
+
+	tests := []struct {
+		policy   TrailingSlashPolicy
+		input    string
+		expected string
+	}{
+		{TrailingSlashStrip, "/", "http://example.com"},
+		{TrailingSlashStrip, "/page/", "http://example.com/page"},
+		{TrailingSlashStrip, "/login?next=/", "http://example.com/login?next=/"},
+		{TrailingSlashAdd, "http://example.com", "http://example.com/"},
+		{TrailingSlashAdd, "/page", "http://example.com/page/"},
+		{TrailingSlashAdd, "/page/", "http://example.com/page/"},
+		{TrailingSlashAdd, "/image.jpg", "http://example.com/image.jpg"},
+		{TrailingSlashPreserve, "http://example.com", "http://example.com/"},
+		{TrailingSlashPreserve, "/page", "http://example.com/page"},
+		{TrailingSlashPreserve, "/page/", "http://example.com/page/"},
+	}
+
+	for _, test := range tests {
+		c.trailingSlashPolicy = test.policy
+
+		if normalized, _ := c.normalizeURL(test.input); normalized != test.expected {
+			t.Errorf("Expected normalized URL '%s' for input '%s' with policy %d, got '%s'", test.expected, test.input, test.policy, normalized)
+		}
+	}
+}
+
 func TestEnsureTrailingSlash(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -87,6 +297,7 @@ func TestEnsureTrailingSlash(t *testing.T) {
 		{"http://example.com/page/", "http://example.com/page/"},
 		{"http://example.com/image.jpg", "http://example.com/image.jpg"},
 		{"http://example.com/folder", "http://example.com/folder/"},
+		{"http://example.com/v1.2/docs", "http://example.com/v1.2/docs/"},
 	}
 
 	for _, test := range tests {
@@ -96,3 +307,1158 @@ func TestEnsureTrailingSlash(t *testing.T) {
 		}
 	}
 }
+
+func TestCrawlConcurrency(t *testing.T) {
+	mockServer := httptest.NewServer(createMockServer())
+	defer mockServer.Close()
+
+	var errorCount atomic.Int32
+
+	c := newCrawler(mockServer.URL, []string{"hx-get"}, func(string) {}, func(error) { errorCount.Add(1) })
+	c.concurrency = 4
+	c.crawl(context.Background(), "/")
+
+	linksFound := []string{}
+	for _, link := range c.getLinks() {
+		linksFound = append(linksFound, link.link)
+	}
+
+	linksRequired := []string{mockServer.URL, mockServer.URL + "/page1", mockServer.URL + "/page2", mockServer.URL + "/htmx"}
+
+	slices.Sort(linksFound)
+	slices.Sort(linksRequired)
+
+	if !slices.Equal(linksFound, linksRequired) {
+		t.Errorf("Expected to find %v, got %v", linksRequired, linksFound)
+	}
+
+	// Both "/nonexistent-link" and "/redirect-url" should have been reported.
+	if errorCount.Load() != 2 {
+		t.Errorf("Expected 2 errors to be logged, got %d", errorCount.Load())
+	}
+}
+
+func TestCrawlFetchesEachURLOnce(t *testing.T) {
+	var mutex sync.Mutex
+	requests := make(map[string]int)
+
+	// Every page links to every other page, so the same URLs get queued by many workers at once.
+	var links strings.Builder
+	for i := range 20 {
+		fmt.Fprintf(&links, `<a href="/page%d">Page %d</a>`, i, i)
+	}
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests[r.URL.Path]++
+		mutex.Unlock()
+
+		time.Sleep(time.Millisecond * 5)
+		w.Write([]byte(links.String()))
+	}))
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	c.concurrency = 8
+	c.crawl(context.Background(), "/")
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// robots.txt isn't requested since it isn't respected.
+	if len(requests) != 21 {
+		t.Errorf("Expected 21 different paths to be requested, got %d", len(requests))
+	}
+
+	for path, count := range requests {
+		if count != 1 {
+			t.Errorf("Expected '%s' to be fetched once, got %d", path, count)
+		}
+	}
+}
+
+func TestCrawlRequestTimeout(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/slow">Slow</a>`))
+	})
+	mux.HandleFunc("GET /slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second * 10):
+		}
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	var errorCount atomic.Int32
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) { errorCount.Add(1) })
+	c.requestTimeout = time.Millisecond * 200
+
+	start := time.Now()
+	c.crawl(context.Background(), "/")
+
+	if elapsed := time.Since(start); elapsed > time.Second*2 {
+		t.Errorf("Expected the slow request to time out, crawl took %v", elapsed)
+	}
+
+	if errorCount.Load() != 1 {
+		t.Errorf("Expected the timeout to be logged as an error, got %d errors", errorCount.Load())
+	}
+
+	if len(c.getLinks()) != 1 {
+		t.Errorf("Expected only the starting URL to be found, got %d links", len(c.getLinks()))
+	}
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	c := newCrawler("http://example.com", nil, nil, nil)
+
+	// The default client shouldn't follow redirects.
+	client := c.newHTTPClient()
+	if client.CheckRedirect == nil {
+		t.Error("Expected the default client to have a CheckRedirect function")
+	}
+
+	// A user supplied client should be copied and given a CheckRedirect function.
+	userClient := &http.Client{Timeout: time.Second * 5}
+	c.httpClient = userClient
+
+	client = c.newHTTPClient()
+	if client == userClient {
+		t.Error("Expected the user supplied client to be copied")
+	}
+
+	if userClient.CheckRedirect != nil {
+		t.Error("Expected the user supplied client to not be modified")
+	}
+
+	if client.CheckRedirect == nil {
+		t.Error("Expected the copied client to have a CheckRedirect function")
+	}
+
+	if client.Timeout != time.Second*5 {
+		t.Errorf("Expected the client's timeout to be kept, got %v", client.Timeout)
+	}
+
+	// The request timeout takes precedence over the client's timeout.
+	c.requestTimeout = time.Second
+	if client = c.newHTTPClient(); client.Timeout != time.Second {
+		t.Errorf("Expected the request timeout to take precedence, got %v", client.Timeout)
+	}
+
+	// A user supplied CheckRedirect function is an explicit opt-in to following redirects.
+	userClient.CheckRedirect = func(req *http.Request, via []*http.Request) error { return nil }
+	if client = c.newHTTPClient(); client.CheckRedirect(nil, nil) != nil {
+		t.Error("Expected the user supplied CheckRedirect function to be kept")
+	}
+}
+
+func TestCrawlProxyURL(t *testing.T) {
+	var proxied atomic.Int32
+
+	// A plain HTTP proxy receives the absolute URLs of the requests it should forward.
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host != "example.test" {
+			http.Error(w, "unexpected host", http.StatusBadGateway)
+			return
+		}
+
+		proxied.Add(1)
+
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<a href="/about">About</a>`))
+		}
+	}))
+	defer proxyServer.Close()
+
+	proxyURL, _ := url.Parse(proxyServer.URL)
+
+	c := newCrawler("http://example.test", nil, func(string) {}, func(error) {})
+	c.proxyURL = proxyURL
+	c.crawl(context.Background(), "/")
+
+	if n := proxied.Load(); n != 2 {
+		t.Errorf("Expected 2 requests to go through the proxy, got %d", n)
+	}
+
+	if links := c.getLinks(); len(links) != 2 {
+		t.Errorf("Expected 2 links to be crawled through the proxy, got %d", len(links))
+	}
+
+	// The user's transport shouldn't be modified.
+	userTransport := &http.Transport{}
+	c.httpClient = &http.Client{Transport: userTransport}
+
+	client := c.newHTTPClient()
+	if client.Transport == userTransport || userTransport.Proxy != nil {
+		t.Error("Expected the user supplied transport to be copied")
+	}
+}
+
+func TestCrawlUserAgent(t *testing.T) {
+	var userAgent atomic.Value
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		userAgent.Store(r.UserAgent())
+		w.Write([]byte("<h1>Home</h1>"))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	c.crawl(context.Background(), "/")
+
+	if userAgent.Load() != DefaultUserAgent {
+		t.Errorf("Expected User-Agent to be '%s', got '%v'", DefaultUserAgent, userAgent.Load())
+	}
+
+	c.userAgent = "mybot/1.0"
+	c.crawl(context.Background(), "/")
+
+	if userAgent.Load() != "mybot/1.0" {
+		t.Errorf("Expected User-Agent to be 'mybot/1.0', got '%v'", userAgent.Load())
+	}
+}
+
+func TestCrawlMaxCrawlDuration(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+		// Every page takes a while and links to another page, so the crawl would never end.
+		select {
+		case <-time.After(time.Millisecond * 50):
+		case <-r.Context().Done():
+			return
+		}
+
+		fmt.Fprintf(w, `<a href="%s/next">Next</a>`, strings.TrimSuffix(r.URL.Path, "/"))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	c.concurrency = 2
+	c.maxCrawlDuration = time.Millisecond * 300
+
+	start := time.Now()
+	err := c.crawl(context.Background(), "/")
+
+	if !errors.Is(err, ErrMaxCrawlDuration) {
+		t.Errorf("Expected ErrMaxCrawlDuration, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second*2 {
+		t.Errorf("Expected the crawl to stop after its time budget, took %v", elapsed)
+	}
+
+	if links := c.getLinks(); len(links) == 0 {
+		t.Error("Expected the pages found before the time ran out to be kept")
+	}
+}
+
+func TestCrawlMaxDepth(t *testing.T) {
+	mockServer := httptest.NewServer(createMockServer())
+	defer mockServer.Close()
+
+	tests := []struct {
+		maxDepth int
+		expected []string
+	}{
+		{0, []string{"", "/htmx", "/page1", "/page2"}},
+		{-1, []string{"", "/htmx", "/page1", "/page2"}},
+		{1, []string{"", "/page1", "/page2"}},
+		{2, []string{"", "/htmx", "/page1", "/page2"}},
+	}
+
+	for _, test := range tests {
+		c := newCrawler(mockServer.URL, []string{"hx-get"}, func(string) {}, func(error) {})
+		c.maxDepth = test.maxDepth
+		c.crawl(context.Background(), "/")
+
+		linksFound := []string{}
+		for _, link := range c.getLinks() {
+			linksFound = append(linksFound, strings.TrimPrefix(link.link, mockServer.URL))
+		}
+
+		slices.Sort(linksFound)
+
+		if !slices.Equal(linksFound, test.expected) {
+			t.Errorf("Expected max depth %d to find %v, got %v", test.maxDepth, test.expected, linksFound)
+		}
+	}
+}
+
+func TestCrawlFollowRedirects(t *testing.T) {
+	mux := createMockServer()
+	mux.HandleFunc("GET /old-page", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new-page", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("GET /new-page", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<h1>New Page</h1>"))
+	})
+	mux.HandleFunc("GET /external", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://example.com", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("GET /start", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/">Home</a><a href="/old-page">Old</a><a href="/external">External</a>`))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	var errorCount atomic.Int32
+
+	c := newCrawler(mockServer.URL, []string{"hx-get"}, func(string) {}, func(error) { errorCount.Add(1) })
+	c.followRedirects = true
+	c.crawl(context.Background(), "/start")
+
+	linksFound := []string{}
+	for _, link := range c.getLinks() {
+		linksFound = append(linksFound, strings.TrimPrefix(link.link, mockServer.URL))
+	}
+
+	slices.Sort(linksFound)
+
+	expected := []string{"", "/htmx", "/new-page", "/page1", "/page2", "/start"}
+	if !slices.Equal(linksFound, expected) {
+		t.Errorf("Expected to find %v, got %v", expected, linksFound)
+	}
+
+	// Only "/nonexistent-link" should have been reported. The redirects shouldn't be errors.
+	if errorCount.Load() != 1 {
+		t.Errorf("Expected 1 error to be logged, got %d", errorCount.Load())
+	}
+}
+
+func TestCrawlLastModified(t *testing.T) {
+	lastModified := time.Date(2020, time.June, 15, 12, 0, 0, 0, time.UTC)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/modified">Modified</a>`))
+	})
+	mux.HandleFunc("GET /modified", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+		w.Write([]byte("<h1>Modified</h1>"))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	c.crawl(context.Background(), "/")
+
+	for _, link := range c.getLinks() {
+		switch link.link {
+		case mockServer.URL + "/modified":
+			if !link.lastMod().Equal(lastModified) {
+				t.Errorf("Expected '%s' to use the Last-Modified header, got %v", link.link, link.lastMod())
+			}
+		default:
+			if !link.lastMod().Equal(link.lastChanged) {
+				t.Errorf("Expected '%s' to fall back to the last changed time, got %v", link.link, link.lastMod())
+			}
+		}
+	}
+}
+
+func TestCrawlConditionalRequests(t *testing.T) {
+	lastModified := time.Date(2020, time.June, 15, 12, 0, 0, 0, time.UTC)
+
+	var fullResponses atomic.Int32
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"home"`)
+		if r.Header.Get("If-None-Match") == `"home"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		fullResponses.Add(1)
+		w.Write([]byte(`<a href="/modified">Modified</a>`))
+	})
+	mux.HandleFunc("GET /modified", func(w http.ResponseWriter, r *http.Request) {
+		if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !lastModified.After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		fullResponses.Add(1)
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+		w.Write([]byte(`<a href="/plain">Plain</a>`))
+	})
+	mux.HandleFunc("GET /plain", func(w http.ResponseWriter, r *http.Request) {
+		fullResponses.Add(1)
+		w.Write([]byte("<h1>Plain</h1>"))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	c.useConditionalRequests = true
+
+	c.crawl(context.Background(), "/")
+
+	if n := fullResponses.Load(); n != 3 {
+		t.Errorf("Expected 3 full responses during the first crawl, got %d", n)
+	}
+
+	checksums := make(map[string]string)
+	for _, link := range c.getLinks() {
+		checksums[link.link] = link.checksum
+	}
+
+	fullResponses.Store(0)
+	c.crawl(context.Background(), "/")
+
+	// Only the page without an ETag or Last-Modified header should be fetched in full again, and
+	// it should still be found through the links of the pages that weren't modified.
+	if n := fullResponses.Load(); n != 1 {
+		t.Errorf("Expected 1 full response during the second crawl, got %d", n)
+	}
+
+	stats := c.getStats()
+	if stats.PagesCrawled != 3 || stats.Errors != 0 || stats.ChangedLinks != 0 {
+		t.Errorf("Unexpected stats after the second crawl: %+v", stats)
+	}
+
+	links := c.getLinks()
+	if len(links) != 3 {
+		t.Fatalf("Expected 3 links, got %d", len(links))
+	}
+
+	for _, link := range links {
+		if link.checksum != checksums[link.link] {
+			t.Errorf("Expected the checksum of '%s' to be unchanged", link.link)
+		}
+	}
+}
+
+func TestShouldCrawl(t *testing.T) {
+	c := newCrawler("http://example.com", nil, nil, nil)
+	c.includePatterns = []*regexp.Regexp{regexp.MustCompile(`/blog`)}
+	c.excludePatterns = []*regexp.Regexp{regexp.MustCompile(`/blog/drafts`), regexp.MustCompile(`/admin`)}
+
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"http://example.com/blog", true},
+		{"http://example.com/blog/post", true},
+		{"http://example.com/blog/drafts/post", false},
+		{"http://example.com/about", false},
+		{"http://example.com/admin", false},
+	}
+
+	for _, test := range tests {
+		if result := c.shouldCrawl(test.input); result != test.expected {
+			t.Errorf("Expected shouldCrawl(%q) to be %v, got %v", test.input, test.expected, result)
+		}
+	}
+}
+
+func TestCrawlExcludePatterns(t *testing.T) {
+	var page2Requests atomic.Int32
+
+	mux := createMockServer()
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page2" {
+			page2Requests.Add(1)
+		}
+
+		mux.ServeHTTP(w, r)
+	}))
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, []string{"hx-get"}, func(string) {}, func(error) {})
+	c.excludePatterns = []*regexp.Regexp{regexp.MustCompile(`/page2`)}
+	c.crawl(context.Background(), "/")
+
+	if page2Requests.Load() != 0 {
+		t.Errorf("Expected '/page2' to never be fetched, got %d requests", page2Requests.Load())
+	}
+}
+
+func TestCrawlScopePatterns(t *testing.T) {
+	var requests sync.Map
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Store(r.URL.Path, true)
+		w.Write([]byte(`<a href="/docs/intro">Intro</a><a href="/docs">Docs</a><a href="/blog">Blog</a><a href="/docsearch">Search</a>`))
+	}))
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	c.scopePatterns = []*regexp.Regexp{regexp.MustCompile(`^/docs(/|$)`)}
+	c.crawl(context.Background(), "/docs")
+
+	var paths []string
+	requests.Range(func(key, value any) bool {
+		paths = append(paths, key.(string))
+		return true
+	})
+	slices.Sort(paths)
+
+	expected := []string{"/docs", "/docs/intro"}
+	if !slices.Equal(paths, expected) {
+		t.Errorf("Expected only %v to be fetched, got %v", expected, paths)
+	}
+}
+
+func TestCrawlRespectCanonical(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/product?color=red">Red</a><a href="/product?color=blue">Blue</a>`))
+	})
+	mux.HandleFunc("GET /product", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><link rel="canonical" href="/product"></head></html>`))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	c.respectCanonical = true
+	c.crawl(context.Background(), "/")
+
+	linksFound := []string{}
+	for _, link := range c.getLinks() {
+		linksFound = append(linksFound, strings.TrimPrefix(link.link, mockServer.URL))
+	}
+
+	slices.Sort(linksFound)
+
+	expected := []string{"", "/product"}
+	if !slices.Equal(linksFound, expected) {
+		t.Errorf("Expected to find %v, got %v", expected, linksFound)
+	}
+}
+
+func TestCrawlNoindex(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><meta name="robots" content="noindex"></head><a href="/page1">Page 1</a></html>`))
+	})
+	mux.HandleFunc("GET /page1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body>Page 1</body></html>`))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	c.crawl(context.Background(), "/")
+
+	noindex := make(map[string]bool)
+	for _, link := range c.getLinks() {
+		noindex[strings.TrimPrefix(link.link, mockServer.URL)] = link.noindex
+	}
+
+	// The noindex page should still have been crawled for its links.
+	expected := map[string]bool{"": true, "/page1": false}
+	if !maps.Equal(noindex, expected) {
+		t.Errorf("Expected to find %v, got %v", expected, noindex)
+	}
+}
+
+func TestCrawlXRobotsTag(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Robots-Tag", "googlebot: noindex")
+		w.Write([]byte(`<a href="/page1">Page 1</a><a href="/page2">Page 2</a>`))
+	})
+	mux.HandleFunc("GET /page1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Robots-Tag", "bingbot: noindex")
+		w.Write([]byte(`<html><body>Page 1</body></html>`))
+	})
+	mux.HandleFunc("GET /page2", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("X-Robots-Tag", "noarchive")
+		w.Header().Add("X-Robots-Tag", "NOINDEX, nofollow")
+		w.Write([]byte(`<a href="/page3">Page 3</a>`))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	c.respectNofollow = true
+	c.crawl(context.Background(), "/")
+
+	noindex := make(map[string]bool)
+	for _, link := range c.getLinks() {
+		noindex[strings.TrimPrefix(link.link, mockServer.URL)] = link.noindex
+	}
+
+	// The links on /page2 shouldn't be followed.
+	expected := map[string]bool{"": true, "/page1": false, "/page2": true}
+	if !maps.Equal(noindex, expected) {
+		t.Errorf("Expected to find %v, got %v", expected, noindex)
+	}
+}
+
+func TestCrawlSoft404Pattern(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/missing">Missing</a><a href="/page1">Page 1</a>`))
+	})
+	mux.HandleFunc("GET /missing", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><title>Page not found</title></head><a href="/page2">Page 2</a></html>`))
+	})
+	mux.HandleFunc("GET /page1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body>Page 1</body></html>`))
+	})
+	mux.HandleFunc("GET /page2", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body>Page 2</body></html>`))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	c.soft404Pattern = regexp.MustCompile(`<title>Page not found`)
+	c.crawl(context.Background(), "/")
+
+	indexable := make(map[string]bool)
+	for _, link := range c.getLinks() {
+		indexable[strings.TrimPrefix(link.link, mockServer.URL)] = link.indexable()
+	}
+
+	// The soft 404 page should still have been crawled for its links.
+	expected := map[string]bool{"": true, "/missing": false, "/page1": true, "/page2": true}
+	if !maps.Equal(indexable, expected) {
+		t.Errorf("Expected to find %v, got %v", expected, indexable)
+	}
+}
+
+func TestCrawlStats(t *testing.T) {
+	var version atomic.Int32
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/page1">Page 1</a><a href="/missing">Missing</a>`))
+	})
+	mux.HandleFunc("GET /page1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<h1>Version %d</h1>", version.Load())
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+
+	if stats := c.getStats(); stats != (CrawlStats{}) {
+		t.Errorf("Expected empty stats before the first crawl, got %+v", stats)
+	}
+
+	c.crawl(context.Background(), "/")
+
+	stats := c.getStats()
+	if stats.PagesCrawled != 2 || stats.Errors != 1 || stats.NewLinks != 2 || stats.ChangedLinks != 0 {
+		t.Errorf("Unexpected stats after the first crawl: %+v", stats)
+	}
+
+	if stats.LastCrawlTime.IsZero() || stats.Duration <= 0 {
+		t.Errorf("Expected the crawl time and duration to be set, got %+v", stats)
+	}
+
+	version.Add(1)
+	c.crawl(context.Background(), "/")
+
+	stats = c.getStats()
+	if stats.PagesCrawled != 2 || stats.Errors != 1 || stats.NewLinks != 0 || stats.ChangedLinks != 1 {
+		t.Errorf("Unexpected stats after the second crawl: %+v", stats)
+	}
+}
+
+func TestCrawlLastCrawlTime(t *testing.T) {
+	mockServer := httptest.NewServer(createMockServer())
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+
+	if lastCrawlTime := c.getLastCrawlTime(); !lastCrawlTime.IsZero() {
+		t.Errorf("Expected no last crawl time before the first crawl, got %v", lastCrawlTime)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.crawl(ctx, "/")
+
+	if lastCrawlTime := c.getLastCrawlTime(); !lastCrawlTime.IsZero() {
+		t.Errorf("Expected a cancelled crawl to not set the last crawl time, got %v", lastCrawlTime)
+	}
+
+	before := time.Now()
+	c.crawl(context.Background(), "/")
+
+	if lastCrawlTime := c.getLastCrawlTime(); lastCrawlTime.Before(before) {
+		t.Errorf("Expected the last crawl time to be after %v, got %v", before, lastCrawlTime)
+	}
+}
+
+func TestCrawlAlwaysUpdateLastMod(t *testing.T) {
+	mockServer := httptest.NewServer(createMockServer())
+	defer mockServer.Close()
+
+	for _, always := range []bool{false, true} {
+		c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+		c.alwaysUpdateLastMod = always
+
+		c.crawl(context.Background(), "/")
+
+		firstCrawl := make(map[string]time.Time)
+		for _, link := range c.getLinks() {
+			firstCrawl[link.link] = link.lastChanged
+		}
+
+		time.Sleep(time.Millisecond * 10)
+		c.crawl(context.Background(), "/")
+
+		for _, link := range c.getLinks() {
+			updated := link.lastChanged.After(firstCrawl[link.link])
+			if updated != always {
+				t.Errorf("Expected '%s' to have its last changed time updated to be %t with alwaysUpdateLastMod %t", link.link, always, always)
+			}
+		}
+	}
+}
+
+func TestCrawlFallbackThreshold(t *testing.T) {
+	var down atomic.Bool
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/page1">Page 1</a><a href="/page2">Page 2</a><a href="/page3">Page 3</a>`))
+	})
+	mux.HandleFunc("GET /{page}", func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		fmt.Fprintf(w, "<h1>%s</h1>", r.PathValue("page"))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	c.fallbackThreshold = 50
+
+	if err := c.crawl(context.Background(), "/"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.getLinks()) != 4 {
+		t.Fatalf("Expected 4 links after the first crawl, got %d", len(c.getLinks()))
+	}
+
+	// Only the home page is found, which is 25% of the known pages.
+	down.Store(true)
+
+	if err := c.crawl(context.Background(), "/"); !errors.Is(err, ErrBelowFallbackThreshold) {
+		t.Errorf("Expected ErrBelowFallbackThreshold, got %v", err)
+	}
+
+	if len(c.getLinks()) != 4 {
+		t.Errorf("Expected the previous 4 links to be kept, got %d", len(c.getLinks()))
+	}
+
+	// Without the threshold the crawl replaces the links.
+	c.fallbackThreshold = 0
+
+	if err := c.crawl(context.Background(), "/"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.getLinks()) != 1 {
+		t.Errorf("Expected only the home page to be left, got %d links", len(c.getLinks()))
+	}
+}
+
+func TestCrawlGzipResponse(t *testing.T) {
+	gzipped := func(body string) []byte {
+		var buffer bytes.Buffer
+
+		gzipWriter := gzip.NewWriter(&buffer)
+		gzipWriter.Write([]byte(body))
+		gzipWriter.Close()
+
+		return buffer.Bytes()
+	}
+
+	// The server always sends gzip, whether it was asked for or not.
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(gzipped(`<a href="/page1">Page 1</a>`))
+	})
+	mux.HandleFunc("GET /page1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(gzipped(`<h1>Page 1</h1>`))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	tests := []struct {
+		name   string
+		client *http.Client
+	}{
+		{"Default client", nil},
+		{"Compression disabled", &http.Client{Transport: &http.Transport{DisableCompression: true}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+			c.httpClient = test.client
+			c.crawl(context.Background(), "/")
+
+			if links := c.getLinks(); len(links) != 2 {
+				t.Errorf("Expected to find 2 links, got %v", links)
+			}
+		})
+	}
+}
+
+func TestCrawlContentTypes(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/page1">Page 1</a><a href="/report.pdf">Report</a><a href="/data">Data</a>`))
+	})
+	mux.HandleFunc("GET /page1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xhtml+xml; charset=utf-8")
+		w.Write([]byte(`<html><a href="/page2">Page 2</a></html>`))
+	})
+	mux.HandleFunc("GET /page2", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<h1>Page 2</h1>`))
+	})
+	mux.HandleFunc("GET /report.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte(`%PDF-1.4 <a href="/hidden">Hidden</a>`))
+	})
+	mux.HandleFunc("GET /data", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"html": "<a href=\"/hidden\">Hidden</a>"}`))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	tests := []struct {
+		name         string
+		contentTypes []string
+		expected     []string
+	}{
+		{"Default content types", defaultContentTypes, []string{"", "/page1", "/page2"}},
+		{"Wildcard content type", []string{"text/*", "application/*"}, []string{"", "/data", "/page1", "/page2", "/report.pdf"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+			c.allowedContentTypes = test.contentTypes
+			c.crawl(context.Background(), "/")
+
+			linksFound := []string{}
+			for _, link := range c.getLinks() {
+				linksFound = append(linksFound, strings.TrimPrefix(link.link, mockServer.URL))
+			}
+
+			slices.Sort(linksFound)
+
+			if !slices.Equal(linksFound, test.expected) {
+				t.Errorf("Expected to find %v, got %v", test.expected, linksFound)
+			}
+		})
+	}
+}
+
+func TestCrawlMaxResponseBytes(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/small">Small</a><a href="/large">Large</a>`))
+	})
+	mux.HandleFunc("GET /small", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<h1>Small</h1>`))
+	})
+	mux.HandleFunc("GET /large", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<h1>` + strings.Repeat("Large", 100) + `</h1>`))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	var errs atomic.Int32
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) { errs.Add(1) })
+	c.maxResponseBytes = 100
+	c.crawl(context.Background(), "/")
+
+	linksFound := []string{}
+	for _, link := range c.getLinks() {
+		linksFound = append(linksFound, strings.TrimPrefix(link.link, mockServer.URL))
+	}
+
+	slices.Sort(linksFound)
+
+	expected := []string{"", "/small"}
+	if !slices.Equal(linksFound, expected) {
+		t.Errorf("Expected to find %v, got %v", expected, linksFound)
+	}
+
+	if errs.Load() != 1 {
+		t.Errorf("Expected 1 error for the large page, got %d", errs.Load())
+	}
+}
+
+func TestCrawlPageFetcher(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<div id="app"></div>`))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	rendered := map[string]string{
+		mockServer.URL:            `<nav><a href="/about">About</a><a href="/missing">Missing</a></nav>`,
+		mockServer.URL + "/about": `<h1>About</h1>`,
+	}
+
+	var mutex sync.Mutex
+	fetched := []string{}
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	c.pageFetcher = func(ctx context.Context, url string) ([]byte, error) {
+		mutex.Lock()
+		fetched = append(fetched, strings.TrimPrefix(url, mockServer.URL))
+		mutex.Unlock()
+
+		if html, ok := rendered[url]; ok {
+			return []byte(html), nil
+		}
+
+		return nil, errors.New("render failed")
+	}
+	c.crawl(context.Background(), "/")
+
+	linksFound := []string{}
+	for _, link := range c.getLinks() {
+		linksFound = append(linksFound, strings.TrimPrefix(link.link, mockServer.URL))
+	}
+
+	slices.Sort(linksFound)
+	slices.Sort(fetched)
+
+	if expected := []string{"", "/about"}; !slices.Equal(linksFound, expected) {
+		t.Errorf("Expected to find %v, got %v", expected, linksFound)
+	}
+
+	if expected := []string{"", "/about", "/missing"}; !slices.Equal(fetched, expected) {
+		t.Errorf("Expected the page fetcher to fetch %v, got %v", expected, fetched)
+	}
+
+	if brokenLinks := c.getBrokenLinks(); len(brokenLinks) != 1 || !strings.HasSuffix(brokenLinks[0].URL, "/missing") {
+		t.Errorf("Expected /missing to be a broken link, got %v", brokenLinks)
+	}
+}
+
+func TestCrawlPageCallback(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/page1">Page 1</a><a href="/page2">Page 2</a><a href="/missing">Missing</a>`))
+	})
+	mux.HandleFunc("GET /page1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<h1>Page 1</h1>`))
+	})
+	mux.HandleFunc("GET /page2", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<h1>Page 2</h1>`))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	var mutex sync.Mutex
+	bodies := make(map[string]string)
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	c.concurrency = 2
+	c.pageCallback = func(url string, statusCode int, body []byte) {
+		// The crawler's mutex must not be held while the callback runs.
+		c.isVisited(url)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if statusCode != http.StatusOK {
+			t.Errorf("Expected status code 200 for '%s', got %d", url, statusCode)
+		}
+
+		bodies[strings.TrimPrefix(url, mockServer.URL)] = string(body)
+	}
+	c.crawl(context.Background(), "/")
+
+	expected := map[string]string{
+		"":       `<a href="/page1">Page 1</a><a href="/page2">Page 2</a><a href="/missing">Missing</a>`,
+		"/page1": `<h1>Page 1</h1>`,
+		"/page2": `<h1>Page 2</h1>`,
+	}
+
+	if !maps.Equal(bodies, expected) {
+		t.Errorf("Expected the callback to get %v, got %v", expected, bodies)
+	}
+}
+
+func TestCrawlCookies(t *testing.T) {
+	requireSession := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "abc123" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			next(w, r)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		// The starting page hands out the session.
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123"})
+		w.Write([]byte(`<a href="/private">Private</a>`))
+	})
+	mux.HandleFunc("GET /private", requireSession(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/private/page">Page</a>`))
+	}))
+	mux.HandleFunc("GET /private/page", requireSession(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<h1>Page</h1>`))
+	}))
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		jar      http.CookieJar
+		cookies  []*http.Cookie
+		expected int
+	}{
+		{"No cookies", nil, nil, 1},
+		{"Cookie jar", jar, nil, 3},
+		{"Seeded cookies", nil, []*http.Cookie{{Name: "session", Value: "abc123"}}, 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+			c.cookieJar = test.jar
+			c.cookies = test.cookies
+			c.crawl(context.Background(), "/")
+
+			if links := c.getLinks(); len(links) != test.expected {
+				t.Errorf("Expected to find %d links, got %v", test.expected, links)
+			}
+		})
+	}
+}
+
+func TestCrawlBasicAuth(t *testing.T) {
+	var logs []string
+	var mutex sync.Mutex
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Write([]byte(`<a href="/page1">Page 1</a>`))
+	})
+	mux.HandleFunc("GET /page1", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	log := func(msg string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		logs = append(logs, msg)
+	}
+
+	c := newCrawler(mockServer.URL, nil, log, func(err error) { log(err.Error()) })
+	c.basicAuthUsername = "admin"
+	c.basicAuthPassword = "secret"
+	c.crawl(context.Background(), "/")
+
+	if links := c.getLinks(); len(links) != 1 {
+		t.Errorf("Expected to find 1 link, got %v", links)
+	}
+
+	// The credentials should never end up in the logs.
+	for _, msg := range logs {
+		if strings.Contains(msg, "secret") {
+			t.Errorf("Expected the logs to not contain the password, got '%s'", msg)
+		}
+	}
+
+	// Requests to other hosts shouldn't get the credentials.
+	req, err := c.newRequest(context.Background(), "https://www.google.com/ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, ok := req.BasicAuth(); ok {
+		t.Error("Expected requests to other hosts to not have basic auth")
+	}
+}
+
+func TestNewRequestHeaders(t *testing.T) {
+	c := newCrawler("http://example.com", nil, nil, nil)
+	c.requestHeaders = http.Header{"X-Api-Key": {"abc123"}, "User-Agent": {"ignored"}}
+	c.userAgent = "mybot/1.0"
+	c.basicAuthUsername = "admin"
+
+	req, err := c.newRequest(context.Background(), "http://example.com/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key := req.Header.Get("X-Api-Key"); key != "abc123" {
+		t.Errorf("Expected X-Api-Key to be 'abc123', got '%s'", key)
+	}
+
+	if userAgent := req.Header.Get("User-Agent"); userAgent != "mybot/1.0" {
+		t.Errorf("Expected the user-agent to take precedence, got '%s'", userAgent)
+	}
+
+	if _, _, ok := req.BasicAuth(); !ok {
+		t.Error("Expected the request to have basic auth")
+	}
+
+	// Requests to other hosts shouldn't get the custom headers.
+	req, err = c.newRequest(context.Background(), "https://www.google.com/ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key := req.Header.Get("X-Api-Key"); key != "" {
+		t.Errorf("Expected requests to other hosts to not have X-Api-Key, got '%s'", key)
+	}
+}
@@ -0,0 +1,186 @@
+package sitemapper
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed standard cron expression. Each field is a bit set of the values at
+// which the schedule fires.
+type cronSchedule struct {
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+
+	// daysRestricted and weekdaysRestricted record whether the day of the month and the day of
+	// the week fields didn't start with "*". When both are restricted the schedule fires on days
+	// that match either of them, like cron does.
+	daysRestricted     bool
+	weekdaysRestricted bool
+}
+
+// cronField describes the range of values that a field of a cron expression accepts.
+type cronField struct {
+	name string
+	min  int
+	max  int
+}
+
+var cronFields = [5]cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of the month", 1, 31},
+	{"month", 1, 12},
+	{"day of the week", 0, 7},
+}
+
+// cronMacros are the shorthands that can be used instead of the five fields.
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSearchLimit is how far into the future next looks for a matching time before it gives up.
+const cronSearchLimit = time.Hour * 24 * 366 * 5
+
+// parseCronSchedule parses a standard cron expression with five fields: minute, hour, day of
+// the month, month and day of the week. Every field accepts "*", single values, ranges like
+// "1-5", steps like "*/15" or "1-30/2" and comma separated lists of those. Sunday is both 0
+// and 7. The @yearly, @monthly, @weekly, @daily and @hourly shorthands are supported as well.
+func parseCronSchedule(spec string) (*cronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if macro, has := cronMacros[strings.ToLower(spec)]; has {
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, errors.New("invalid cron schedule: must have 5 fields")
+	}
+
+	var sets [5]uint64
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, err
+		}
+
+		sets[i] = set
+	}
+
+	schedule := &cronSchedule{
+		minutes:            sets[0],
+		hours:              sets[1],
+		days:               sets[2],
+		months:             sets[3],
+		weekdays:           sets[4],
+		daysRestricted:     !strings.HasPrefix(fields[2], "*"),
+		weekdaysRestricted: !strings.HasPrefix(fields[4], "*"),
+	}
+
+	// Sunday can be written as either 0 or 7.
+	if schedule.weekdays&(1<<7) != 0 {
+		schedule.weekdays |= 1
+	}
+
+	if schedule.next(time.Now()).IsZero() {
+		return nil, errors.New("invalid cron schedule: never fires")
+	}
+
+	return schedule, nil
+}
+
+// parseCronField parses a single field of a cron expression into a bit set of its values.
+func parseCronField(field string, bounds cronField) (uint64, error) {
+	invalid := fmt.Errorf("invalid cron schedule: %q is not a valid %s", field, bounds.name)
+
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return 0, invalid
+			}
+		}
+
+		start, end := bounds.min, bounds.max
+		if rangePart != "*" {
+			startPart, endPart, isRange := strings.Cut(rangePart, "-")
+
+			var err error
+			if start, err = strconv.Atoi(startPart); err != nil {
+				return 0, invalid
+			}
+
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(endPart); err != nil {
+					return 0, invalid
+				}
+			} else if hasStep {
+				// "5/15" means every 15 starting at 5.
+				end = bounds.max
+			}
+		}
+
+		if start < bounds.min || end > bounds.max || start > end {
+			return 0, invalid
+		}
+
+		for value := start; value <= end; value += step {
+			set |= 1 << value
+		}
+	}
+
+	return set, nil
+}
+
+// next returns the first time after t at which the schedule fires, in t's location. The zero
+// time is returned if the schedule doesn't fire within the next five years, like on the 30th
+// of February.
+func (schedule *cronSchedule) next(t time.Time) time.Time {
+	limit := t.Add(cronSearchLimit)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	for t.Before(limit) {
+		switch {
+		case schedule.months&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !schedule.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case schedule.hours&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case schedule.minutes&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// matchesDay checks whether the schedule fires on t's day.
+func (schedule *cronSchedule) matchesDay(t time.Time) bool {
+	day := schedule.days&(1<<t.Day()) != 0
+	weekday := schedule.weekdays&(1<<int(t.Weekday())) != 0
+
+	if schedule.daysRestricted && schedule.weekdaysRestricted {
+		return day || weekday
+	}
+
+	return day && weekday
+}
@@ -0,0 +1,38 @@
+package sitemapper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2025, time.January, 1, 12, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2025, time.January, 1, 12, 31, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2025, time.January, 2, 3, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, time.January, 1, 12, 45, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2025, time.January, 1, 13, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2025, time.January, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, time.January, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 15 * 5", time.Date(2025, time.January, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		schedule, err := parseCronSchedule(test.spec)
+		if err != nil {
+			t.Errorf("parseCronSchedule(%q) returned an error: %v", test.spec, err)
+			continue
+		}
+
+		if next := schedule.next(now); !next.Equal(test.expected) {
+			t.Errorf("Expected %q to fire next at %v, got %v", test.spec, test.expected, next)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package sitemapper
+
+// eventBufferSize is the number of events that are buffered for a slow consumer before new
+// events get dropped.
+const eventBufferSize = 100
+
+// CrawlEventType describes what happened in a CrawlEvent.
+type CrawlEventType int
+
+const (
+	// CrawlStarted is emitted when a crawl starts. The URL is the starting URL.
+	CrawlStarted CrawlEventType = iota
+
+	// PageFetched is emitted when a page has been fetched and parsed successfully.
+	PageFetched
+
+	// PageError is emitted when a page couldn't be crawled. The error describes what went wrong.
+	PageError
+
+	// CrawlFinished is emitted when a crawl finishes. The URL is the starting URL and the error
+	// is set if the crawl got cancelled.
+	CrawlFinished
+)
+
+// String returns the name of the event type.
+func (eventType CrawlEventType) String() string {
+	switch eventType {
+	case CrawlStarted:
+		return "CrawlStarted"
+	case PageFetched:
+		return "PageFetched"
+	case PageError:
+		return "PageError"
+	case CrawlFinished:
+		return "CrawlFinished"
+	default:
+		return "Unknown"
+	}
+}
+
+// CrawlEvent is a structured description of something that happened during a crawl.
+type CrawlEvent struct {
+	// Type describes what happened.
+	Type CrawlEventType
+
+	// URL is the URL the event is about.
+	URL string
+
+	// Err is the error that occurred, if any.
+	Err error
+}
+
+// Events returns the channel on which the crawl events are delivered. This can be used to
+// drive things like a progress bar or metrics without having to parse the log messages.
+//
+// Sending events never blocks the crawl. Up to 100 events are buffered and any events that
+// occur while the buffer is full are dropped, so the channel should be drained promptly.
+// The channel is never closed.
+func (mapper *SiteMapper) Events() <-chan CrawlEvent {
+	return mapper.spider.events
+}
+
+// emit delivers the event without blocking. The event is dropped if nobody is keeping up
+// with the events.
+func (crawler *crawler) emit(event CrawlEvent) {
+	select {
+	case crawler.events <- event:
+	default:
+	}
+}
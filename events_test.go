@@ -0,0 +1,83 @@
+package sitemapper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCrawlEvents(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/page1">Page 1</a><a href="/missing">Missing</a>`))
+	})
+	mux.HandleFunc("GET /page1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<h1>Page 1</h1>"))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	c.crawl(context.Background(), "/")
+
+	var events []CrawlEvent
+	for len(c.events) > 0 {
+		events = append(events, <-c.events)
+	}
+
+	if len(events) != 5 {
+		t.Fatalf("Expected 5 events, got %v", events)
+	}
+
+	if events[0].Type != CrawlStarted || events[0].URL != mockServer.URL {
+		t.Errorf("Expected the first event to be CrawlStarted for '%s', got %+v", mockServer.URL, events[0])
+	}
+
+	if last := events[len(events)-1]; last.Type != CrawlFinished || last.Err != nil {
+		t.Errorf("Expected the last event to be a successful CrawlFinished, got %+v", last)
+	}
+
+	counts := make(map[CrawlEventType]int)
+	for _, event := range events {
+		counts[event.Type]++
+
+		if event.Type == PageError && (event.URL != mockServer.URL+"/missing" || event.Err == nil) {
+			t.Errorf("Expected a PageError with an error for '/missing', got %+v", event)
+		}
+	}
+
+	if counts[PageFetched] != 2 || counts[PageError] != 1 {
+		t.Errorf("Expected 2 PageFetched and 1 PageError events, got %v", counts)
+	}
+}
+
+func TestEmitDoesNotBlock(t *testing.T) {
+	c := newCrawler("http://example.com", nil, nil, nil)
+
+	// Nobody is reading the events so the ones that don't fit in the buffer get dropped.
+	for range eventBufferSize * 2 {
+		c.emit(CrawlEvent{Type: PageFetched})
+	}
+
+	if len(c.events) != eventBufferSize {
+		t.Errorf("Expected %d buffered events, got %d", eventBufferSize, len(c.events))
+	}
+}
+
+func TestCrawlEventTypeString(t *testing.T) {
+	tests := map[CrawlEventType]string{
+		CrawlStarted:       "CrawlStarted",
+		PageFetched:        "PageFetched",
+		PageError:          "PageError",
+		CrawlFinished:      "CrawlFinished",
+		CrawlEventType(42): "Unknown",
+	}
+
+	for eventType, expected := range tests {
+		if eventType.String() != expected {
+			t.Errorf("Expected '%s', got '%s'", expected, eventType.String())
+		}
+	}
+}
@@ -3,3 +3,5 @@ module github.com/PsionicAlch/sitemapper
 go 1.23.4
 
 require golang.org/x/net v0.39.0
+
+require golang.org/x/text v0.24.0 // indirect
@@ -0,0 +1,107 @@
+package sitemapper
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const (
+	// GraphFormatDOT writes the link graph in the GraphViz DOT language.
+	GraphFormatDOT = "dot"
+
+	// GraphFormatJSON writes the link graph as a JSON object that maps every URL to the sorted
+	// list of URLs it links to.
+	GraphFormatJSON = "json"
+)
+
+// ExportGraph writes the link graph of the latest crawl to w in the given format, which is either
+// GraphFormatDOT or GraphFormatJSON. The nodes are the normalized URLs that were found and the
+// edges are the links between them. Visualizing the graph helps with spotting sections of the
+// site that are over-linked or that nothing links to.
+//
+// Links to pages outside of the domain are only included, as leaf nodes, when they were enabled
+// with SetIncludeExternalLinksInGraph. In the DOT format they're drawn with a dashed outline.
+func (mapper *SiteMapper) ExportGraph(w io.Writer, format string) error {
+	graph := mapper.spider.linkGraph()
+
+	switch format {
+	case GraphFormatDOT:
+		return mapper.spider.writeDOTGraph(w, graph)
+	case GraphFormatJSON:
+		if err := json.NewEncoder(w).Encode(graph); err != nil {
+			return fmt.Errorf("failed to export graph: %w", err)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("invalid graph format: %q must be %q or %q", format, GraphFormatDOT, GraphFormatJSON)
+	}
+}
+
+// linkGraph builds the adjacency list of the latest crawl, mapping every known URL to the sorted
+// list of URLs it links to.
+func (crawler *crawler) linkGraph() map[string][]string {
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	graph := make(map[string][]string, len(crawler.links))
+	for link := range crawler.links {
+		graph[link] = []string{}
+	}
+
+	for link, referrers := range crawler.linkReferrers {
+		if _, has := graph[link]; !has {
+			graph[link] = []string{}
+		}
+
+		for _, referrer := range referrers {
+			graph[referrer] = append(graph[referrer], link)
+		}
+	}
+
+	for _, links := range graph {
+		slices.Sort(links)
+	}
+
+	return graph
+}
+
+// writeDOTGraph writes the adjacency list to w in the GraphViz DOT language.
+func (crawler *crawler) writeDOTGraph(w io.Writer, graph map[string][]string) error {
+	buf := bufio.NewWriter(w)
+	nodes := slices.Sorted(maps.Keys(graph))
+
+	buf.WriteString("digraph sitemap {\n")
+
+	for _, node := range nodes {
+		if crawler.inDomain(node) {
+			fmt.Fprintf(buf, "\t%s;\n", dotID(node))
+		} else {
+			fmt.Fprintf(buf, "\t%s [style=dashed];\n", dotID(node))
+		}
+	}
+
+	for _, node := range nodes {
+		for _, link := range graph[node] {
+			fmt.Fprintf(buf, "\t%s -> %s;\n", dotID(node), dotID(link))
+		}
+	}
+
+	buf.WriteString("}\n")
+
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("failed to export graph: %w", err)
+	}
+
+	return nil
+}
+
+// dotID quotes the URL so that it can be used as a node ID in the DOT language.
+func dotID(link string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(link) + `"`
+}
@@ -0,0 +1,114 @@
+package sitemapper
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportGraph(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/about">About</a><a href="https://Example.com/page#top">Example</a><a href="mailto:me@example.com">Mail</a>`))
+	})
+	mux.HandleFunc("GET /about", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/">Home</a><a href="/missing">Missing</a>`))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	tests := []struct {
+		name            string
+		includeExternal bool
+		expectedJSON    map[string][]string
+		expectedDOT     string
+	}{
+		{
+			"Internal links only",
+			false,
+			map[string][]string{
+				mockServer.URL:              {mockServer.URL + "/about"},
+				mockServer.URL + "/about":   {mockServer.URL, mockServer.URL + "/missing"},
+				mockServer.URL + "/missing": {},
+			},
+			`digraph sitemap {
+	"{url}";
+	"{url}/about";
+	"{url}/missing";
+	"{url}" -> "{url}/about";
+	"{url}/about" -> "{url}";
+	"{url}/about" -> "{url}/missing";
+}
+`,
+		},
+		{
+			"External links included",
+			true,
+			map[string][]string{
+				mockServer.URL:              {mockServer.URL + "/about", "https://example.com/page"},
+				mockServer.URL + "/about":   {mockServer.URL, mockServer.URL + "/missing"},
+				mockServer.URL + "/missing": {},
+				"https://example.com/page":  {},
+			},
+			`digraph sitemap {
+	"{url}";
+	"{url}/about";
+	"{url}/missing";
+	"https://example.com/page" [style=dashed];
+	"{url}" -> "{url}/about";
+	"{url}" -> "https://example.com/page";
+	"{url}/about" -> "{url}";
+	"{url}/about" -> "{url}/missing";
+}
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+			c.includeExternalLinksInGraph = test.includeExternal
+			c.crawl(context.Background(), "/")
+
+			mapper := &SiteMapper{spider: c, domain: mockServer.URL}
+
+			var jsonGraph strings.Builder
+			if err := mapper.ExportGraph(&jsonGraph, GraphFormatJSON); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var graph map[string][]string
+			if err := json.Unmarshal([]byte(jsonGraph.String()), &graph); err != nil {
+				t.Fatalf("Failed to parse JSON graph: %v", err)
+			}
+
+			if !reflect.DeepEqual(graph, test.expectedJSON) {
+				t.Errorf("Expected JSON graph %v, got %v", test.expectedJSON, graph)
+			}
+
+			var dotGraph strings.Builder
+			if err := mapper.ExportGraph(&dotGraph, GraphFormatDOT); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			expectedDOT := strings.ReplaceAll(test.expectedDOT, "{url}", mockServer.URL)
+			if dotGraph.String() != expectedDOT {
+				t.Errorf("Expected DOT graph:\n%s\ngot:\n%s", expectedDOT, dotGraph.String())
+			}
+		})
+	}
+}
+
+func TestExportGraphInvalidFormat(t *testing.T) {
+	mapper := &SiteMapper{spider: newCrawler("http://localhost:8080", nil, func(string) {}, func(error) {})}
+
+	expected := `invalid graph format: "svg" must be "dot" or "json"`
+	if err := mapper.ExportGraph(&strings.Builder{}, "svg"); err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, got %v", expected, err)
+	}
+}
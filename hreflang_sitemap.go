@@ -0,0 +1,77 @@
+package sitemapper
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+type hreflangSitemapLink struct {
+	XMLName  xml.Name `xml:"xhtml:link"`
+	Rel      string   `xml:"rel,attr"`
+	Hreflang string   `xml:"hreflang,attr"`
+	Href     string   `xml:"href,attr"`
+}
+
+type hreflangSitemapURL struct {
+	XMLName      xml.Name              `xml:"url"`
+	Location     string                `xml:"loc"`
+	LastModified string                `xml:"lastmod,omitempty"`
+	Alternates   []hreflangSitemapLink `xml:"xhtml:link"`
+}
+
+type hreflangSitemapURLSet struct {
+	XMLName    xml.Name             `xml:"urlset"`
+	Xmlns      string               `xml:"xmlns,attr"`
+	XmlnsXhtml string               `xml:"xmlns:xhtml,attr"`
+	URLS       []hreflangSitemapURL `xml:"url"`
+}
+
+// GenerateHreflangSitemap generates a sitemap that lists the alternate-language versions of the
+// pages, which is how search engines like them to be expressed for international sites. Every
+// page that declares alternates through <link rel="alternate" hreflang="..." href="..."> tags
+// gets a <url> entry with an <xhtml:link> entry for each of them.
+//
+// Pages with a noindex robots meta tag and soft 404 pages are skipped. The crawled domain is
+// replaced with baseDomain the same way GenerateSitemap does it, while alternates on other
+// domains are left alone.
+func (mapper *SiteMapper) GenerateHreflangSitemap(baseDomain string) (string, error) {
+	urlSet := hreflangSitemapURLSet{
+		Xmlns:      "http://www.sitemaps.org/schemas/sitemap/0.9",
+		XmlnsXhtml: "http://www.w3.org/1999/xhtml",
+	}
+
+	for _, link := range mapper.orderedLinks() {
+		if !link.indexable() || len(link.alternates) == 0 {
+			continue
+		}
+
+		url := hreflangSitemapURL{
+			Location:     replaceDomain(link.link, mapper.domain, baseDomain),
+			LastModified: mapper.formatLastMod(link.lastMod()),
+		}
+
+		for _, alternate := range link.alternates {
+			url.Alternates = append(url.Alternates, hreflangSitemapLink{
+				Rel:      "alternate",
+				Hreflang: alternate.hreflang,
+				Href:     replaceDomain(alternate.href, mapper.domain, baseDomain),
+			})
+		}
+
+		urlSet.URLS = append(urlSet.URLS, url)
+	}
+
+	var builder strings.Builder
+
+	builder.WriteString(xmlDeclaration)
+
+	encoder := xml.NewEncoder(&builder)
+	encoder.Indent("", mapper.sitemapIndent)
+
+	if err := encoder.Encode(urlSet); err != nil {
+		return "", fmt.Errorf("failed to generate xml: %w", err)
+	}
+
+	return builder.String(), nil
+}
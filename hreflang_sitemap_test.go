@@ -0,0 +1,43 @@
+package sitemapper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateHreflangSitemap(t *testing.T) {
+	mapper := newTestSiteMapper(
+		crawlerURL{link: "http://example.com/page", alternates: []alternateLink{
+			{hreflang: "en", href: "http://example.com/page"},
+			{hreflang: "fr", href: "http://example.com/fr/page"},
+			{hreflang: "de", href: "https://example.de/page"},
+		}},
+		crawlerURL{link: "http://example.com/about"},
+		crawlerURL{link: "http://example.com/private", alternates: []alternateLink{{hreflang: "fr", href: "http://example.com/fr/private"}}, noindex: true},
+	)
+
+	sitemap, err := mapper.GenerateHreflangSitemap("https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`xmlns:xhtml="http://www.w3.org/1999/xhtml"`,
+		"<loc>https://example.com/page</loc>",
+		`<xhtml:link rel="alternate" hreflang="en" href="https://example.com/page"></xhtml:link>`,
+		`<xhtml:link rel="alternate" hreflang="fr" href="https://example.com/fr/page"></xhtml:link>`,
+		`<xhtml:link rel="alternate" hreflang="de" href="https://example.de/page"></xhtml:link>`,
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(sitemap, e) {
+			t.Errorf("Expected hreflang sitemap to contain '%s', got:\n%s", e, sitemap)
+		}
+	}
+
+	for _, unexpected := range []string{"/about", "private"} {
+		if strings.Contains(sitemap, unexpected) {
+			t.Errorf("Expected hreflang sitemap to not contain '%s', got:\n%s", unexpected, sitemap)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package sitemapper
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+type imageSitemapImage struct {
+	XMLName  xml.Name `xml:"image:image"`
+	Location string   `xml:"image:loc"`
+}
+
+type imageSitemapURL struct {
+	XMLName      xml.Name            `xml:"url"`
+	Location     string              `xml:"loc"`
+	LastModified string              `xml:"lastmod,omitempty"`
+	Images       []imageSitemapImage `xml:"image:image"`
+}
+
+type imageSitemapURLSet struct {
+	XMLName    xml.Name          `xml:"urlset"`
+	Xmlns      string            `xml:"xmlns,attr"`
+	XmlnsImage string            `xml:"xmlns:image,attr"`
+	URLS       []imageSitemapURL `xml:"url"`
+}
+
+// GenerateImageSitemap generates a sitemap using the image sitemap extension. Every page that
+// contains images gets a <url> entry with an <image:image> entry for each of its images, which
+// helps search engines find images that they might otherwise miss.
+//
+// Only the images from <img src> tags within the domain are included, unless external images
+// were allowed with SetIncludeExternalImages. Pages with a noindex robots meta tag and soft 404
+// pages are skipped.
+// The crawled domain is replaced with baseDomain the same way GenerateSitemap does it.
+func (mapper *SiteMapper) GenerateImageSitemap(baseDomain string) (string, error) {
+	urlSet := imageSitemapURLSet{
+		Xmlns:      "http://www.sitemaps.org/schemas/sitemap/0.9",
+		XmlnsImage: "http://www.google.com/schemas/sitemap-image/1.1",
+	}
+
+	for _, link := range mapper.orderedLinks() {
+		if !link.indexable() || len(link.images) == 0 {
+			continue
+		}
+
+		url := imageSitemapURL{
+			Location:     replaceDomain(link.link, mapper.domain, baseDomain),
+			LastModified: mapper.formatLastMod(link.lastMod()),
+		}
+
+		for _, image := range link.images {
+			url.Images = append(url.Images, imageSitemapImage{
+				Location: replaceDomain(image, mapper.domain, baseDomain),
+			})
+		}
+
+		urlSet.URLS = append(urlSet.URLS, url)
+	}
+
+	var builder strings.Builder
+
+	builder.WriteString(xmlDeclaration)
+
+	encoder := xml.NewEncoder(&builder)
+	encoder.Indent("", mapper.sitemapIndent)
+
+	if err := encoder.Encode(urlSet); err != nil {
+		return "", fmt.Errorf("failed to generate xml: %w", err)
+	}
+
+	return builder.String(), nil
+}
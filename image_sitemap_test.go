@@ -0,0 +1,38 @@
+package sitemapper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateImageSitemap(t *testing.T) {
+	mapper := newTestSiteMapper(
+		crawlerURL{link: "http://example.com/gallery", images: []string{"http://example.com/images/1.jpg", "https://cdn.example.org/2.jpg"}},
+		crawlerURL{link: "http://example.com/about"},
+		crawlerURL{link: "http://example.com/private", images: []string{"http://example.com/images/3.jpg"}, noindex: true},
+	)
+
+	sitemap, err := mapper.GenerateImageSitemap("https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`xmlns:image="http://www.google.com/schemas/sitemap-image/1.1"`,
+		"<loc>https://example.com/gallery</loc>",
+		"<image:image>\n\t\t\t<image:loc>https://example.com/images/1.jpg</image:loc>\n\t\t</image:image>",
+		"<image:loc>https://cdn.example.org/2.jpg</image:loc>",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(sitemap, e) {
+			t.Errorf("Expected image sitemap to contain '%s', got:\n%s", e, sitemap)
+		}
+	}
+
+	for _, unexpected := range []string{"/about", "/private", "3.jpg"} {
+		if strings.Contains(sitemap, unexpected) {
+			t.Errorf("Expected image sitemap to not contain '%s', got:\n%s", unexpected, sitemap)
+		}
+	}
+}
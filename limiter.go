@@ -0,0 +1,55 @@
+package sitemapper
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter ensures that there is at least a set delay between successive requests,
+// regardless of how many workers are making them.
+type rateLimiter struct {
+	// mutex ensures thread-safe access to next.
+	mutex sync.Mutex
+
+	// delay is the minimum amount of time between two requests.
+	delay time.Duration
+
+	// next is the earliest time at which the next request may be made.
+	next time.Time
+}
+
+// newRateLimiter creates a new rateLimiter with the given delay. A delay of 0 or less
+// doesn't limit anything.
+func newRateLimiter(delay time.Duration) *rateLimiter {
+	return &rateLimiter{
+		delay: delay,
+	}
+}
+
+// wait blocks until the next request may be made. It returns the context's error if
+// the context gets cancelled while waiting.
+func (limiter *rateLimiter) wait(ctx context.Context) error {
+	if limiter == nil || limiter.delay <= 0 {
+		return nil
+	}
+
+	// Reserve the next available slot so that concurrent callers are spaced out.
+	limiter.mutex.Lock()
+	slot := limiter.next
+	if now := time.Now(); slot.Before(now) {
+		slot = now
+	}
+	limiter.next = slot.Add(limiter.delay)
+	limiter.mutex.Unlock()
+
+	timer := time.NewTimer(time.Until(slot))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,46 @@
+package sitemapper
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(time.Millisecond * 50)
+
+	start := time.Now()
+
+	// Five concurrent requests should be spaced out by the delay.
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limiter.wait(context.Background())
+		}()
+	}
+
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed < time.Millisecond*200 {
+		t.Errorf("Expected 5 requests to take at least 200ms, took %v", elapsed)
+	}
+}
+
+func TestRateLimiterCancelled(t *testing.T) {
+	limiter := newRateLimiter(time.Hour)
+
+	// The first request doesn't have to wait.
+	if err := limiter.wait(context.Background()); err != nil {
+		t.Errorf("Expected the first request to not wait, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	if err := limiter.wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package sitemapper
+
+import "net/url"
+
+// LinkExtractor finds the links on a page, for sites whose links can't be found in the HTML,
+// like a navigation that is stored in a <script type="application/json"> blob.
+//
+// Extract is called from the crawler's goroutines, so implementations must be safe for
+// concurrent use.
+type LinkExtractor interface {
+	// Extract returns the links on the page at base. The body has been decompressed and decoded
+	// to UTF-8. Links can be relative to base and are normalized and filtered just like the links
+	// the crawler finds itself. Returning nil falls back to the links found by the built-in HTML
+	// extractor.
+	Extract(base string, body []byte) []string
+}
+
+// extractorLinks runs the link extractor over the body of the page and splits the links it returns
+// into the ones within the domain and the external ones. It returns false if the extractor didn't
+// return any links, in which case the links found by the tokenizer should be used.
+func (crawler *crawler) extractorLinks(pageURL string, body []byte) ([]string, []string, bool) {
+	extracted := crawler.linkExtractor.Extract(pageURL, body)
+	if extracted == nil {
+		return nil, nil, false
+	}
+
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		base = nil
+	}
+
+	links := []string{}
+	var externalLinks []string
+
+	for _, link := range extracted {
+		if normalized, ok := crawler.normalizeURLAgainst(link, base); ok {
+			links = append(links, normalized)
+		} else if external, ok := crawler.externalURL(link, base); ok {
+			externalLinks = append(externalLinks, external)
+		}
+	}
+
+	return links, externalLinks, true
+}
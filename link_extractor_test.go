@@ -0,0 +1,72 @@
+package sitemapper
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// navigationExtractor reads the links from the JSON navigation of a page, if it has one.
+type navigationExtractor struct{}
+
+var navigationPattern = regexp.MustCompile(`(?s)<script type="application/json" id="nav">(.*?)</script>`)
+
+func (navigationExtractor) Extract(base string, body []byte) []string {
+	match := navigationPattern.FindSubmatch(body)
+	if match == nil {
+		return nil
+	}
+
+	var links []string
+	json.Unmarshal(match[1], &links)
+
+	return links
+}
+
+func TestCrawlLinkExtractor(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<script type="application/json" id="nav">["/a", "b", "https://other.com/"]</script><a href="/c">C</a>`))
+	})
+	mux.HandleFunc("GET /a", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/d">D</a>`))
+	})
+	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<h1>Page</h1>`))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	c.linkExtractor = navigationExtractor{}
+	c.includeExternalLinksInGraph = true
+	c.crawl(context.Background(), "/")
+
+	linksFound := []string{}
+	var externalLinks []string
+	for _, link := range c.getLinks() {
+		linksFound = append(linksFound, strings.TrimPrefix(link.link, mockServer.URL))
+
+		if link.link == mockServer.URL {
+			externalLinks = link.externalLinks
+		}
+	}
+
+	slices.Sort(linksFound)
+
+	// The extractor replaces the links on the home page, while /a falls back to its <a> tags.
+	expected := []string{"", "/a", "/b", "/d"}
+	if !slices.Equal(linksFound, expected) {
+		t.Errorf("Expected to find %v, got %v", expected, linksFound)
+	}
+
+	if !slices.Equal(externalLinks, []string{"https://other.com/"}) {
+		t.Errorf("Expected the external links of the extractor to be recorded, got %v", externalLinks)
+	}
+}
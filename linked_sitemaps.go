@@ -0,0 +1,118 @@
+package sitemapper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// linkedSitemap is a sitemap, or a sitemap index, that is linked to from robots.txt.
+type linkedSitemap struct {
+	// URLs are the pages listed by a sitemap.
+	URLs []linkedSitemapEntry `xml:"url"`
+
+	// Sitemaps are the sitemaps listed by a sitemap index.
+	Sitemaps []linkedSitemapEntry `xml:"sitemap"`
+}
+
+// linkedSitemapEntry is a <url> or <sitemap> entry of a linkedSitemap.
+type linkedSitemapEntry struct {
+	Location string `xml:"loc"`
+}
+
+// importSitemaps fetches the sitemaps, and the sitemaps referenced by sitemap indexes, and returns
+// the normalized pages in them that should be crawled. Sitemaps outside of the domain are skipped.
+func (crawler *crawler) importSitemaps(ctx context.Context, client *http.Client, sitemaps []string) []string {
+	links := []string{}
+	fetched := make(map[string]bool)
+
+	for len(sitemaps) > 0 && ctx.Err() == nil {
+		sitemapURL := strings.TrimSpace(sitemaps[0])
+		sitemaps = sitemaps[1:]
+
+		// Sitemap indexes could reference each other.
+		if fetched[sitemapURL] {
+			continue
+		}
+
+		fetched[sitemapURL] = true
+
+		if _, ok := crawler.normalizeURL(sitemapURL); !ok {
+			crawler.infoLogger(fmt.Sprintf("Skipping sitemap '%s' as it is outside of the domain", sitemapURL))
+			continue
+		}
+
+		sitemap, err := crawler.fetchSitemap(ctx, client, sitemapURL)
+		if err != nil {
+			if ctx.Err() == nil {
+				crawler.logCrawlError(sitemapURL, err)
+			}
+
+			continue
+		}
+
+		for _, entry := range sitemap.Sitemaps {
+			sitemaps = append(sitemaps, entry.Location)
+		}
+
+		for _, entry := range sitemap.URLs {
+			if link, ok := crawler.normalizeURL(strings.TrimSpace(entry.Location)); ok && crawler.shouldCrawl(link) {
+				links = append(links, link)
+			}
+		}
+	}
+
+	return links
+}
+
+// fetchSitemap fetches and parses a single sitemap or sitemap index. Sitemaps compressed with
+// gzip, like "sitemap.xml.gz", are decompressed.
+func (crawler *crawler) fetchSitemap(ctx context.Context, client *http.Client, sitemapURL string) (*linkedSitemap, error) {
+	if err := crawler.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	req, err := crawler.newRequest(ctx, sitemapURL)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request for \"%s\": %w", sitemapURL, err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching \"%s\": %w", sitemapURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("\"%s\" did not return status code 200: %d", sitemapURL, resp.StatusCode)
+	}
+
+	body, err := readBody(resp, crawler.maxResponseBytes)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body of \"%s\": %w", sitemapURL, err)
+	}
+
+	// A sitemap.xml.gz file is usually served as is instead of with a gzip Content-Encoding.
+	if bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+		gzipReader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("error decompressing \"%s\": %w", sitemapURL, err)
+		}
+
+		if body, err = io.ReadAll(io.LimitReader(gzipReader, crawler.maxResponseBytes)); err != nil {
+			return nil, fmt.Errorf("error decompressing \"%s\": %w", sitemapURL, err)
+		}
+	}
+
+	var sitemap linkedSitemap
+	if err := xml.Unmarshal(body, &sitemap); err != nil {
+		return nil, fmt.Errorf("error parsing \"%s\": %w", sitemapURL, err)
+	}
+
+	return &sitemap, nil
+}
@@ -0,0 +1,97 @@
+package sitemapper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestCrawlImportLinkedSitemaps(t *testing.T) {
+	var serverURL string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/linked">Linked</a>`))
+	})
+	mux.HandleFunc("GET /linked", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<h1>Linked</h1>`))
+	})
+	mux.HandleFunc("GET /orphan", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/orphan-child">Child</a>`))
+	})
+	mux.HandleFunc("GET /orphan-child", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<h1>Child</h1>`))
+	})
+	mux.HandleFunc("GET /gzipped", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<h1>Gzipped</h1>`))
+	})
+	mux.HandleFunc("GET /robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "User-agent: *\nDisallow:\n\nSitemap: %s/sitemap-index.xml\nSitemap: https://other.example.org/sitemap.xml\n", serverURL)
+	})
+	mux.HandleFunc("GET /sitemap-index.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap><loc>%[1]s/sitemap-1.xml</loc></sitemap>
+	<sitemap><loc>%[1]s/sitemap-2.xml.gz</loc></sitemap>
+	<sitemap><loc>%[1]s/sitemap-index.xml</loc></sitemap>
+</sitemapindex>`, serverURL)
+	})
+	mux.HandleFunc("GET /sitemap-1.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url><loc>%s/orphan</loc></url>
+	<url><loc>https://other.example.org/page</loc></url>
+</urlset>`, serverURL)
+	})
+	mux.HandleFunc("GET /sitemap-2.xml.gz", func(w http.ResponseWriter, r *http.Request) {
+		var buffer bytes.Buffer
+
+		gzipWriter := gzip.NewWriter(&buffer)
+		fmt.Fprintf(gzipWriter, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>%s/gzipped</loc></url></urlset>`, serverURL)
+		gzipWriter.Close()
+
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Write(buffer.Bytes())
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	serverURL = mockServer.URL
+
+	crawl := func(importSitemaps bool) []string {
+		c := newCrawler(mockServer.URL, nil, func(string) {}, func(err error) { t.Log(err) })
+		c.importLinkedSitemaps = importSitemaps
+		c.crawl(context.Background(), "/")
+
+		links := []string{}
+		for _, link := range c.getLinks() {
+			links = append(links, link.link)
+		}
+
+		slices.Sort(links)
+
+		return links
+	}
+
+	expected := []string{mockServer.URL, mockServer.URL + "/linked"}
+	if links := crawl(false); !slices.Equal(links, expected) {
+		t.Errorf("Expected %v, got %v", expected, links)
+	}
+
+	expected = []string{
+		mockServer.URL,
+		mockServer.URL + "/gzipped",
+		mockServer.URL + "/linked",
+		mockServer.URL + "/orphan",
+		mockServer.URL + "/orphan-child",
+	}
+	if links := crawl(true); !slices.Equal(links, expected) {
+		t.Errorf("Expected %v, got %v", expected, links)
+	}
+}
@@ -0,0 +1,62 @@
+package sitemapper
+
+import (
+	"fmt"
+	"time"
+)
+
+// AddURL adds a page to the sitemap without crawling it, for pages that can't be discovered by
+// following links, like a download that is only reachable through a form. The URL can be absolute
+// or relative to the domain and is normalized the same way the links the crawler finds are. An
+// error is returned if it's outside of the domain.
+//
+// The page stays in the sitemap until it's removed with RemoveURL, whether or not a crawl finds it.
+func (mapper *SiteMapper) AddURL(rawURL string) error {
+	return mapper.spider.addURL(rawURL)
+}
+
+// RemoveURL removes a page from the sitemap, including the ones added with AddURL. A page that is
+// still linked to will be found again by the next crawl. Removing a URL that isn't in the sitemap
+// does nothing.
+func (mapper *SiteMapper) RemoveURL(rawURL string) error {
+	return mapper.spider.removeURL(rawURL)
+}
+
+// addURL adds the URL to the known links and marks it as added by hand.
+func (crawler *crawler) addURL(rawURL string) error {
+	link, ok := crawler.normalizeURL(rawURL)
+	if !ok {
+		return fmt.Errorf("invalid URL: %q is not within the domain", rawURL)
+	}
+
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	url, has := crawler.links[link]
+	if !has {
+		now := time.Now()
+		url = crawlerURL{link: link, lastChanged: now, lastSeen: now}
+	}
+
+	url.manual = true
+	crawler.links[link] = url
+	crawler.metrics.SetKnownLinks(len(crawler.links))
+
+	return nil
+}
+
+// removeURL removes the URL from the known links.
+func (crawler *crawler) removeURL(rawURL string) error {
+	link, ok := crawler.normalizeURL(rawURL)
+	if !ok {
+		return fmt.Errorf("invalid URL: %q is not within the domain", rawURL)
+	}
+
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	delete(crawler.links, link)
+	crawler.metrics.SetKnownLinks(len(crawler.links))
+
+	return nil
+}
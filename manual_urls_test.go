@@ -0,0 +1,82 @@
+package sitemapper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestAddURL(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/about">About</a>`))
+	})
+	mux.HandleFunc("GET /about", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<h1>About</h1>`))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	mapper := &SiteMapper{spider: c, domain: mockServer.URL}
+
+	tests := []struct {
+		input    string
+		expected error
+	}{
+		{"/downloads/guide.pdf", nil},
+		{mockServer.URL + "/about", nil},
+		{"https://example.com/page", errors.New(`invalid URL: "https://example.com/page" is not within the domain`)},
+	}
+
+	for _, test := range tests {
+		err := mapper.AddURL(test.input)
+		if (err == nil && test.expected != nil) || (err != nil && err.Error() != test.expected.Error()) {
+			t.Errorf("Expected error: %v, got: %v", test.expected, err)
+		}
+	}
+
+	urls := func() []string {
+		var urls []string
+		for _, link := range mapper.Links() {
+			urls = append(urls, link.URL)
+		}
+		return urls
+	}
+
+	// The added URLs should survive crawls that don't find them.
+	c.crawl(context.Background(), "/")
+
+	expected := []string{mockServer.URL, mockServer.URL + "/about", mockServer.URL + "/downloads/guide.pdf"}
+	if links := urls(); !slices.Equal(links, expected) {
+		t.Errorf("Expected links %v, got %v", expected, links)
+	}
+
+	// Only the link that wasn't added by hand should be pruned.
+	if pruned := mapper.PruneStaleLinks(0); pruned != 1 {
+		t.Errorf("Expected 1 link to be pruned, got %d", pruned)
+	}
+
+	if err := mapper.RemoveURL("/downloads/guide.pdf"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := mapper.RemoveURL("/about"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if links := urls(); len(links) != 0 {
+		t.Errorf("Expected no links after removing them, got %v", links)
+	}
+
+	c.crawl(context.Background(), "/")
+
+	expected = []string{mockServer.URL, mockServer.URL + "/about"}
+	if links := urls(); !slices.Equal(links, expected) {
+		t.Errorf("Expected links %v, got %v", expected, links)
+	}
+}
@@ -0,0 +1,53 @@
+package sitemapper
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+// MergeSitemaps generates a single sitemap from the links of several SiteMappers, like when
+// separate SiteMappers crawl "/shop" and "/blog" on their own schedules but the site publishes one
+// sitemap. Each SiteMapper contributes every URL it would put in its own sitemap without a filter,
+// with its crawled domain replaced by baseDomain.
+//
+// A URL that more than one SiteMapper knows about is only listed once, with the most recent
+// lastmod. The URLs are sorted alphabetically and the sitemap is indented like the sitemaps of the
+// first SiteMapper. Nil SiteMappers are skipped and an error is returned if none are left.
+func MergeSitemaps(baseDomain string, mappers ...*SiteMapper) (string, error) {
+	mappers = slices.DeleteFunc(slices.Clone(mappers), func(mapper *SiteMapper) bool { return mapper == nil })
+	if len(mappers) == 0 {
+		return "", errors.New("sitemapper: no SiteMappers to merge")
+	}
+
+	var urls []datedSitemapURL
+
+	// seen maps each location to the index of its URL.
+	seen := make(map[string]int)
+
+	for _, mapper := range mappers {
+		for _, url := range mapper.datedSitemapURLs(baseDomain, sitemapFilter{}) {
+			if previous, has := seen[url.Location]; has {
+				if url.lastMod.After(urls[previous].lastMod) {
+					urls[previous] = url
+				}
+
+				continue
+			}
+
+			seen[url.Location] = len(urls)
+			urls = append(urls, url)
+		}
+	}
+
+	slices.SortFunc(urls, func(a, b datedSitemapURL) int {
+		return strings.Compare(a.Location, b.Location)
+	})
+
+	merged := make([]sitemapURL, len(urls))
+	for i, url := range urls {
+		merged[i] = url.sitemapURL
+	}
+
+	return mappers[0].marshalURLSet(merged)
+}
@@ -0,0 +1,48 @@
+package sitemapper
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMergeSitemaps(t *testing.T) {
+	older := time.Date(2025, time.January, 2, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2025, time.March, 4, 0, 0, 0, 0, time.UTC)
+
+	shop := newTestSiteMapper(
+		crawlerURL{link: "http://example.com", lastChanged: older},
+		crawlerURL{link: "http://example.com/shop", lastChanged: older},
+		crawlerURL{link: "http://example.com/shop/private", lastChanged: older, noindex: true},
+	)
+
+	blog := newTestSiteMapper(
+		crawlerURL{link: "http://example.com", lastChanged: newer},
+		crawlerURL{link: "http://example.com/blog", lastChanged: newer},
+	)
+
+	sitemap, err := MergeSitemaps("https://example.com", shop, nil, blog)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	urls, err := extractURLsFromSitemap(sitemap)
+	if err != nil {
+		t.Fatalf("Failed to parse the sitemap: %v", err)
+	}
+
+	expected := []string{"https://example.com", "https://example.com/blog", "https://example.com/shop"}
+	if !slices.Equal(urls, expected) {
+		t.Errorf("Expected URLs %v, got %v", expected, urls)
+	}
+
+	// The home page is known to both, so it should have the most recent lastmod.
+	if !strings.Contains(sitemap, "<loc>https://example.com</loc>\n\t\t<lastmod>2025-03-04</lastmod>") {
+		t.Errorf("Expected the home page to have the most recent lastmod, got %s", sitemap)
+	}
+
+	if _, err := MergeSitemaps("https://example.com"); err == nil {
+		t.Error("Expected an error when there are no SiteMappers to merge")
+	}
+}
@@ -0,0 +1,35 @@
+package sitemapper
+
+import "time"
+
+// Metrics receives the measurements SiteMapper makes while crawling, so that they can be
+// exported to a monitoring system like Prometheus without SiteMapper depending on it.
+//
+// The methods are called from the crawler's goroutines, so implementations must be safe for
+// concurrent use. They are called while the crawler holds internal locks and should return
+// quickly without calling back into SiteMapper.
+type Metrics interface {
+	// IncPagesCrawled is called for every page that was fetched successfully.
+	IncPagesCrawled()
+
+	// IncCrawlErrors is called for every error that occurred while crawling.
+	IncCrawlErrors()
+
+	// ObserveCrawlDuration is called with the duration of every crawl once it finishes.
+	ObserveCrawlDuration(duration time.Duration)
+
+	// SetKnownLinks is called with the total number of known links once a crawl finishes.
+	SetKnownLinks(n int)
+
+	// SetLastCrawlTime is called with the time at which a crawl finished.
+	SetLastCrawlTime(t time.Time)
+}
+
+// noopMetrics is the Metrics implementation that discards every measurement.
+type noopMetrics struct{}
+
+func (noopMetrics) IncPagesCrawled()                   {}
+func (noopMetrics) IncCrawlErrors()                    {}
+func (noopMetrics) ObserveCrawlDuration(time.Duration) {}
+func (noopMetrics) SetKnownLinks(int)                  {}
+func (noopMetrics) SetLastCrawlTime(time.Time)         {}
@@ -0,0 +1,92 @@
+package sitemapper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testMetrics records the measurements it receives.
+type testMetrics struct {
+	mutex         sync.Mutex
+	pagesCrawled  int
+	crawlErrors   int
+	durations     []time.Duration
+	knownLinks    int
+	lastCrawlTime time.Time
+}
+
+func (m *testMetrics) IncPagesCrawled() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.pagesCrawled++
+}
+
+func (m *testMetrics) IncCrawlErrors() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.crawlErrors++
+}
+
+func (m *testMetrics) ObserveCrawlDuration(duration time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.durations = append(m.durations, duration)
+}
+
+func (m *testMetrics) SetKnownLinks(n int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.knownLinks = n
+}
+
+func (m *testMetrics) SetLastCrawlTime(t time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.lastCrawlTime = t
+}
+
+func TestCrawlMetrics(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/page1">Page 1</a><a href="/missing">Missing</a>`))
+	})
+	mux.HandleFunc("GET /page1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<h1>Page 1</h1>"))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	metrics := &testMetrics{}
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	c.metrics = metrics
+	c.crawl(context.Background(), "/")
+
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	if metrics.pagesCrawled != 2 {
+		t.Errorf("Expected 2 pages crawled, got %d", metrics.pagesCrawled)
+	}
+
+	if metrics.crawlErrors != 1 {
+		t.Errorf("Expected 1 crawl error, got %d", metrics.crawlErrors)
+	}
+
+	if len(metrics.durations) != 1 {
+		t.Errorf("Expected 1 crawl duration, got %v", metrics.durations)
+	}
+
+	if metrics.knownLinks != 2 {
+		t.Errorf("Expected 2 known links, got %d", metrics.knownLinks)
+	}
+
+	if !metrics.lastCrawlTime.Equal(c.getStats().LastCrawlTime) {
+		t.Errorf("Expected last crawl time to be %v, got %v", c.getStats().LastCrawlTime, metrics.lastCrawlTime)
+	}
+}
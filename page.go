@@ -0,0 +1,552 @@
+package sitemapper
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// initialLinksCapacity is the number of links that room is made for up front when parsing a page,
+// which is enough for most pages to never grow the slice.
+const initialLinksCapacity = 64
+
+// pageInfo is the information the crawler extracts from a page.
+type pageInfo struct {
+	// links are the normalized in-domain links found on the page.
+	links []string
+
+	// externalLinks are the absolute links to pages outside of the domain found on the page. They
+	// are only collected when the crawler includes external links in the link graph.
+	externalLinks []string
+
+	// canonical is the normalized canonical URL declared by the page through a
+	// <link rel="canonical"> tag. It's empty if there is none or if it's outside of the domain.
+	canonical string
+
+	// nofollow is set when the page asks for its links to not be followed through a
+	// <meta name="robots" content="nofollow"> tag.
+	nofollow bool
+
+	// noindex is set when the page asks to be left out of search results through a
+	// <meta name="robots" content="noindex"> tag.
+	noindex bool
+
+	// images are the absolute URLs of the images found in the page's <img src> tags, without
+	// duplicates. Images outside of the domain are only included when the crawler allows it.
+	images []string
+
+	// alternates are the alternate-language versions of the page declared through
+	// <link rel="alternate" hreflang="..."> tags, without duplicates.
+	alternates []alternateLink
+
+	// videos are the videos embedded in the page through <video> tags. They're only collected
+	// when the crawler has been configured to extract videos.
+	videos []videoInfo
+
+	// checksum is the hex encoded SHA-256 hash of the content of the page that changes are
+	// detected on. It's only computed when that isn't simply the raw body, so when the crawler
+	// detects changes based on the visible text or leaves some elements out of the checksum.
+	checksum string
+}
+
+// alternateLink is an alternate-language version of a page.
+type alternateLink struct {
+	// hreflang is the language, and optionally the region, of the alternate version, like "fr"
+	// or "en-GB". It's "x-default" for the version that is used when no other language matches.
+	hreflang string
+
+	// href is the absolute URL of the alternate version.
+	href string
+}
+
+// extractLinks parses HTML content and extracts links based on the specified attributes.
+func (crawler *crawler) extractLinks(r io.Reader) []string {
+	return crawler.parsePage(r).links
+}
+
+// parsePage parses HTML content and extracts the links, based on the specified attributes,
+// as well as the other information the crawler needs from the page.
+func (crawler *crawler) parsePage(r io.Reader) pageInfo {
+	page := pageInfo{
+		links: make([]string, 0, initialLinksCapacity),
+	}
+
+	// The attributes are looked up in sets since every attribute of every tag is checked.
+	linkAttrs := attributeSet(crawler.linkAttributes)
+	jsonLinkAttrs := attributeSet(crawler.jsonLinkAttributes)
+
+	// checksum is nil unless the checksum can't be computed over the raw body.
+	checksum := crawler.newContentHasher()
+
+	// attrs holds the attributes of the current tag. It's reused for every tag instead of having
+	// the tokenizer allocate a new token each time.
+	var attrs []html.Attribute
+
+	// base is the URL that relative URLs are resolved against. It's set by the page's <base href>
+	// tag and the domain is used as long as there is none.
+	var base *url.URL
+
+	// videos is nil unless videos should be extracted.
+	var videos *videoCollector
+	if crawler.extractVideos {
+		videos = &videoCollector{crawler: crawler}
+	}
+
+	tokenizer := html.NewTokenizer(r)
+
+	for {
+		tt := tokenizer.Next()
+
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+
+			attrs = attrs[:0]
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = tokenizer.TagAttr()
+				attrs = append(attrs, html.Attribute{Key: atom.String(key), Val: string(val)})
+			}
+
+			token := html.Token{Type: tt, Data: atom.String(name), Attr: attrs}
+
+			if checksum != nil {
+				checksum.startTag(token, tokenizer.Raw())
+			}
+
+			// Only the first <base href> counts, just like in browsers. It can be relative to the
+			// domain itself.
+			if token.Data == "base" && base == nil {
+				if href, ok := getAttr(token, "href"); ok {
+					base = crawler.baseURL(href)
+				}
+			}
+
+			if videos != nil {
+				videos.startTag(token, tt == html.SelfClosingTagToken, base)
+			}
+
+			// Handle <a> tags specifically. This is necessary because <link> tags also use
+			// href attributes and there is no reason why we'd ever want to crawl a <link>.
+			if token.Data == "a" {
+				// Skip links that ask not to be followed.
+				if crawler.respectNofollow && hasAttrValue(token, "rel", "nofollow") {
+					continue
+				}
+
+				for _, attr := range token.Attr {
+					if attr.Key == "href" {
+						link := attr.Val
+						if normalized, ok := crawler.normalizeURLAgainst(link, base); ok {
+							page.links = append(page.links, normalized)
+						} else if external, ok := crawler.externalURL(link, base); ok {
+							page.externalLinks = append(page.externalLinks, external)
+						}
+					}
+				}
+			} else {
+				// Handle the other tags based on what the user has configured.
+				for _, attr := range token.Attr {
+					for _, link := range attrLinks(attr, linkAttrs, jsonLinkAttrs) {
+						if normalized, ok := crawler.normalizeURLAgainst(link, base); ok {
+							page.links = append(page.links, normalized)
+						} else if external, ok := crawler.externalURL(link, base); ok {
+							page.externalLinks = append(page.externalLinks, external)
+						}
+					}
+				}
+			}
+
+			// Collect the page's images.
+			if token.Data == "img" {
+				if src, ok := getAttr(token, "src"); ok {
+					if image, ok := crawler.imageURL(src, base); ok && !slices.Contains(page.images, image) {
+						page.images = append(page.images, image)
+					}
+				}
+			}
+
+			// Remember the canonical URL if the page declares one.
+			if token.Data == "link" && page.canonical == "" && hasAttrValue(token, "rel", "canonical") {
+				if href, ok := getAttr(token, "href"); ok {
+					if normalized, ok := crawler.normalizeURLAgainst(href, base); ok {
+						page.canonical = normalized
+					}
+				}
+			}
+
+			// Collect the alternate-language versions of the page.
+			if token.Data == "link" && hasAttrValue(token, "rel", "alternate") {
+				hreflang, hasHreflang := getAttr(token, "hreflang")
+				href, hasHref := getAttr(token, "href")
+
+				if hreflang = strings.TrimSpace(hreflang); hasHreflang && hasHref && hreflang != "" {
+					if alternate, ok := crawler.alternateURL(href, base); ok {
+						link := alternateLink{hreflang: hreflang, href: alternate}
+						if !slices.Contains(page.alternates, link) {
+							page.alternates = append(page.alternates, link)
+						}
+					}
+				}
+			}
+
+			// Check whether the page asks for its links to not be followed or for itself to
+			// not be indexed.
+			if token.Data == "meta" && isRobotsMeta(token) {
+				content, _ := getAttr(token, "content")
+				directives := parseRobotsDirectives(content)
+
+				if slices.Contains(directives, "nofollow") {
+					page.nofollow = true
+				}
+
+				if slices.Contains(directives, "noindex") {
+					page.noindex = true
+				}
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+
+			if checksum != nil {
+				checksum.endTag(string(name), tokenizer.Raw())
+			}
+
+			if videos != nil {
+				videos.endTag(string(name))
+			}
+		case html.TextToken:
+			if videos == nil && checksum == nil {
+				continue
+			}
+
+			// The raw text has to be hashed before it's unescaped in place.
+			if checksum != nil {
+				checksum.rawToken(tokenizer.Raw())
+			}
+
+			content := tokenizer.Text()
+
+			if checksum != nil {
+				checksum.text(content)
+			}
+
+			if videos != nil {
+				videos.text(string(content))
+			}
+		case html.CommentToken, html.DoctypeToken:
+			if checksum != nil {
+				checksum.rawToken(tokenizer.Raw())
+			}
+		case html.ErrorToken:
+			// End of the document or an error. None of the links should be followed if
+			// the page asked for it.
+			if crawler.respectNofollow && page.nofollow {
+				page.links = []string{}
+				page.externalLinks = nil
+			}
+
+			if videos != nil {
+				page.videos = videos.finish()
+			}
+
+			if checksum != nil {
+				page.checksum = checksum.sum()
+			}
+
+			return page
+		}
+	}
+}
+
+// imageURL resolves the src of an image against base, or against the domain if base is nil.
+// Images outside of the domain are rejected unless the crawler has been configured to include
+// external images.
+func (crawler *crawler) imageURL(src string, base *url.URL) (string, bool) {
+	// Things like data URIs can't be listed in a sitemap.
+	image, ok := crawler.absoluteURL(src, base)
+	if !ok {
+		return "", false
+	}
+
+	if !crawler.includeExternalImages && !crawler.inDomain(image) {
+		return "", false
+	}
+
+	return image, true
+}
+
+// externalURL resolves the href of a link against base, or against the domain if base is nil, and
+// returns it if it points to a page outside of the domain. Nothing is returned unless the crawler
+// has been configured to include external links in the link graph.
+func (crawler *crawler) externalURL(href string, base *url.URL) (string, bool) {
+	if !crawler.includeExternalLinksInGraph {
+		return "", false
+	}
+
+	// Links like "mailto:" and "javascript:" don't lead to pages.
+	external, ok := crawler.absoluteURL(href, base)
+	if !ok || crawler.inDomain(external) {
+		return "", false
+	}
+
+	return external, true
+}
+
+// alternateURL resolves the href of an alternate-language link against base, or against the
+// domain if base is nil. Alternates within the domain are normalized like any other link, while
+// the ones outside of it, like the ones on a country-specific domain, are only made absolute.
+func (crawler *crawler) alternateURL(href string, base *url.URL) (string, bool) {
+	if normalized, ok := crawler.normalizeURLAgainst(href, base); ok {
+		return normalized, true
+	}
+
+	return crawler.absoluteURL(href, base)
+}
+
+// absoluteURL resolves the href against base, or against the domain if base is nil, and removes
+// the fragment. Only "http" and "https" URLs are returned.
+func (crawler *crawler) absoluteURL(href string, base *url.URL) (string, bool) {
+	if strings.TrimSpace(href) == "" {
+		return "", false
+	}
+
+	parsedURL, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", false
+	}
+
+	// Resolve relative URLs against the base URL or the base domain.
+	if !parsedURL.IsAbs() {
+		baseURL := base
+		if baseURL == nil {
+			if baseURL, err = url.Parse(crawler.domain); err != nil {
+				return "", false
+			}
+		}
+		parsedURL = baseURL.ResolveReference(parsedURL)
+	}
+
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return "", false
+	}
+
+	parsedURL.Host = strings.ToLower(parsedURL.Host)
+	parsedURL.Fragment = ""
+
+	return parsedURL.String(), true
+}
+
+// baseURL resolves the href of a <base> tag against the domain. It returns nil if the href
+// isn't a valid URL so that the domain keeps being used.
+func (crawler *crawler) baseURL(href string) *url.URL {
+	parsedURL, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return nil
+	}
+
+	domainURL, err := url.Parse(crawler.domain)
+	if err != nil {
+		return nil
+	}
+
+	return domainURL.ResolveReference(parsedURL)
+}
+
+// attributeSet turns a list of attributes into a set for quick lookups.
+func attributeSet(attributes []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(attributes))
+	for _, attribute := range attributes {
+		set[attribute] = struct{}{}
+	}
+
+	return set
+}
+
+// attrLinks returns the URLs in the value of the attribute, if it's one of the link attributes
+// the crawler has been configured to look for links in.
+func attrLinks(attr html.Attribute, linkAttrs map[string]struct{}, jsonLinkAttrs map[string]struct{}) []string {
+	// JSON arrays are only parsed for the attributes that opted in, so that a normal attribute
+	// that happens to start with "[" isn't affected.
+	if _, has := jsonLinkAttrs[attr.Key]; has {
+		if value := strings.TrimSpace(attr.Val); strings.HasPrefix(value, "[") {
+			return parseJSONLinks(value)
+		}
+
+		return []string{attr.Val}
+	}
+
+	if _, has := linkAttrs[attr.Key]; !has {
+		return nil
+	}
+
+	// A srcset contains multiple URLs, each followed by its descriptors.
+	if attr.Key == "srcset" {
+		return parseSrcset(attr.Val)
+	}
+
+	return []string{attr.Val}
+}
+
+// parseJSONLinks returns the strings in a JSON array, like `["/a", "/b"]`. Elements that aren't
+// strings are ignored and nothing is returned if the array is malformed.
+func parseJSONLinks(value string) []string {
+	var elements []any
+	if err := json.Unmarshal([]byte(value), &elements); err != nil {
+		return nil
+	}
+
+	links := []string{}
+	for _, element := range elements {
+		if link, ok := element.(string); ok {
+			links = append(links, link)
+		}
+	}
+
+	return links
+}
+
+// parseSrcset returns the URLs of the image candidates in a srcset attribute, like
+// "small.jpg 480w, large.jpg 1080w", without their descriptors.
+//
+// The candidates are separated by commas, but URLs can contain commas as well. A URL only ends
+// at whitespace, or at the commas it ends with, the same way browsers parse it.
+func parseSrcset(srcset string) []string {
+	urls := []string{}
+
+	for {
+		// Skip the whitespace and commas in front of the next candidate.
+		srcset = strings.TrimLeft(srcset, " \t\n\r\f,")
+		if srcset == "" {
+			return urls
+		}
+
+		end := strings.IndexAny(srcset, " \t\n\r\f")
+		if end < 0 {
+			end = len(srcset)
+		}
+
+		candidate := srcset[:end]
+		srcset = srcset[end:]
+
+		// A URL that ends with a comma has no descriptors.
+		if trimmed := strings.TrimRight(candidate, ","); trimmed != candidate {
+			urls = append(urls, trimmed)
+			continue
+		}
+
+		urls = append(urls, candidate)
+
+		// Skip the descriptors up to the comma that ends the candidate. Commas inside of
+		// parentheses don't end it.
+		depth := 0
+		end = strings.IndexFunc(srcset, func(r rune) bool {
+			switch r {
+			case '(':
+				depth++
+			case ')':
+				depth = max(depth-1, 0)
+			case ',':
+				return depth == 0
+			}
+
+			return false
+		})
+		if end < 0 {
+			return urls
+		}
+
+		srcset = srcset[end+1:]
+	}
+}
+
+// getAttr returns the value of the token's attribute with the given key.
+func getAttr(token html.Token, key string) (string, bool) {
+	for _, attr := range token.Attr {
+		if attr.Key == key {
+			return attr.Val, true
+		}
+	}
+
+	return "", false
+}
+
+// hasAttrValue checks whether the token's attribute with the given key contains the value
+// as one of its whitespace separated values, ignoring case. This is how attributes like
+// rel="canonical nofollow" work.
+func hasAttrValue(token html.Token, key string, value string) bool {
+	attr, ok := getAttr(token, key)
+	if !ok {
+		return false
+	}
+
+	for _, field := range strings.Fields(attr) {
+		if strings.EqualFold(field, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isRobotsMeta checks whether the token is a <meta name="robots"> tag.
+func isRobotsMeta(token html.Token) bool {
+	name, _ := getAttr(token, "name")
+	return strings.EqualFold(strings.TrimSpace(name), "robots")
+}
+
+// valuedRobotsDirectives are the robots directives that take a value after a colon, like
+// "max-snippet:50", which shouldn't be mistaken for the name of a crawler.
+var valuedRobotsDirectives = []string{"unavailable_after", "max-snippet", "max-image-preview", "max-video-preview"}
+
+// robotsHeaderDirectives returns the lowercase directives of the X-Robots-Tag headers of a
+// response. Directives can be aimed at a single crawler by prefixing them with its name, like
+// "googlebot: noindex", in which case only the ones aimed at Googlebot are used since that's
+// the crawler the sitemap is most commonly submitted to.
+func robotsHeaderDirectives(values []string) []string {
+	directives := []string{}
+
+	for _, value := range values {
+		if name, rest, found := strings.Cut(value, ":"); found {
+			name = strings.ToLower(strings.TrimSpace(name))
+
+			// The value is aimed at a crawler if what comes before the colon is a single name.
+			if !slices.Contains(valuedRobotsDirectives, name) && !strings.ContainsAny(name, ", ") {
+				if name != "googlebot" {
+					continue
+				}
+
+				value = rest
+			}
+		}
+
+		directives = append(directives, parseRobotsDirectives(value)...)
+	}
+
+	return directives
+}
+
+// parseRobotsDirectives splits the content of a robots meta tag into its lowercase directives.
+// The "none" shorthand is expanded into "noindex" and "nofollow", and "all" into "index" and
+// "follow".
+func parseRobotsDirectives(content string) []string {
+	directives := []string{}
+
+	for _, directive := range strings.Split(content, ",") {
+		switch directive = strings.ToLower(strings.TrimSpace(directive)); directive {
+		case "":
+		case "none":
+			directives = append(directives, "noindex", "nofollow")
+		case "all":
+			directives = append(directives, "index", "follow")
+		default:
+			directives = append(directives, directive)
+		}
+	}
+
+	return directives
+}
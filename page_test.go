@@ -0,0 +1,415 @@
+package sitemapper
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParsePageCanonical(t *testing.T) {
+	c := newCrawler("http://example.com", nil, nil, nil)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`<link rel="canonical" href="http://example.com/page">`, "http://example.com/page"},
+		{`<link rel="canonical" href="/page">`, "http://example.com/page"},
+		{`<link rel="Canonical" href="/page">`, "http://example.com/page"},
+		{`<link rel="canonical" href="https://otherdomain.com/page">`, ""},
+		{`<link rel="stylesheet" href="/style.css">`, ""},
+		{`<link rel="canonical">`, ""},
+		{`<a href="/page">Page</a>`, ""},
+	}
+
+	for _, test := range tests {
+		page := c.parsePage(strings.NewReader("<html><head>" + test.input + "</head></html>"))
+		if page.canonical != test.expected {
+			t.Errorf("Expected canonical URL '%s' for '%s', got '%s'", test.expected, test.input, page.canonical)
+		}
+	}
+}
+
+func TestParsePageAlternates(t *testing.T) {
+	c := newCrawler("http://example.com", nil, nil, nil)
+
+	htmlContent := `
+	<html>
+		<head>
+			<link rel="alternate" hreflang="en" href="/page">
+			<link rel="alternate" hreflang="fr" href="/fr/page#top">
+			<link rel="alternate" hreflang="de" href="https://Example.de/page">
+			<link rel="alternate" hreflang="x-default" href="/page">
+			<link rel="alternate" hreflang="fr" href="/fr/page">
+			<link rel="alternate" type="application/rss+xml" href="/feed.xml">
+			<link rel="stylesheet" hreflang="en" href="/style.css">
+			<link rel="alternate" hreflang="es" href="mailto:me@example.com">
+		</head>
+	</html>
+	`
+
+	expected := []alternateLink{
+		{hreflang: "en", href: "http://example.com/page"},
+		{hreflang: "fr", href: "http://example.com/fr/page"},
+		{hreflang: "de", href: "https://example.de/page"},
+		{hreflang: "x-default", href: "http://example.com/page"},
+	}
+
+	if page := c.parsePage(strings.NewReader(htmlContent)); !slices.Equal(page.alternates, expected) {
+		t.Errorf("Expected alternates %v, got %v", expected, page.alternates)
+	}
+}
+
+func TestParsePageVideos(t *testing.T) {
+	c := newCrawler("http://example.com", nil, nil, nil)
+
+	htmlContent := `
+	<html>
+		<head>
+			<title>Tutorials</title>
+			<meta name="description" content="Learn how it works">
+		</head>
+		<body>
+			<video src="/videos/labelled.mp4" title="Labelled video" poster="/thumbs/labelled.jpg"></video>
+			<h2>Getting <em>started</em></h2>
+			<video poster="https://cdn.example.org/thumbs/intro.jpg">
+				<source src="/videos/intro.webm#t=10" type="video/webm">
+				<source src="/videos/intro.mp4" type="video/mp4">
+			</video>
+			<video><source src="/videos/intro.webm"></video>
+			<video></video>
+		</body>
+	</html>
+	`
+
+	// Videos are only collected when the crawler is configured to extract them.
+	if page := c.parsePage(strings.NewReader(htmlContent)); page.videos != nil {
+		t.Errorf("Expected no videos, got %v", page.videos)
+	}
+
+	c.extractVideos = true
+
+	expected := []videoInfo{
+		{contentURL: "http://example.com/videos/labelled.mp4", thumbnailURL: "http://example.com/thumbs/labelled.jpg", title: "Labelled video", description: "Learn how it works"},
+		{contentURL: "http://example.com/videos/intro.webm", thumbnailURL: "https://cdn.example.org/thumbs/intro.jpg", title: "Getting started", description: "Learn how it works"},
+	}
+
+	if page := c.parsePage(strings.NewReader(htmlContent)); !slices.Equal(page.videos, expected) {
+		t.Errorf("Expected videos %v, got %v", expected, page.videos)
+	}
+
+	// The title of the page is used when there is no heading before the video, and the title is
+	// used as the description when the page doesn't have one.
+	page := c.parsePage(strings.NewReader(`<html><head><title>Demo</title></head><body><video src="/demo.mp4"></video></body></html>`))
+
+	expected = []videoInfo{{contentURL: "http://example.com/demo.mp4", title: "Demo", description: "Demo"}}
+	if !slices.Equal(page.videos, expected) {
+		t.Errorf("Expected videos %v, got %v", expected, page.videos)
+	}
+}
+
+func TestParsePageNofollow(t *testing.T) {
+	c := newCrawler("http://example.com", nil, nil, nil)
+
+	htmlContent := `
+	<html>
+		<body>
+			<a href="/page1">Page 1</a>
+			<a href="/page2" rel="nofollow">Page 2</a>
+			<a href="/page3" rel="noopener NoFollow">Page 3</a>
+		</body>
+	</html>
+	`
+
+	// Without respecting nofollow every link should be found.
+	if links := c.extractLinks(strings.NewReader(htmlContent)); len(links) != 3 {
+		t.Errorf("Expected to find 3 links, got %v", links)
+	}
+
+	c.respectNofollow = true
+
+	links := c.extractLinks(strings.NewReader(htmlContent))
+	if !slices.Equal(links, []string{"http://example.com/page1"}) {
+		t.Errorf("Expected to only find '/page1', got %v", links)
+	}
+
+	// A nofollow robots meta tag means none of the links should be followed.
+	metaContent := `<html><head><meta name="robots" content="index, nofollow"></head>` + htmlContent + `</html>`
+
+	if links := c.extractLinks(strings.NewReader(metaContent)); len(links) != 0 {
+		t.Errorf("Expected to find no links, got %v", links)
+	}
+}
+
+func TestParseRobotsDirectives(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected []string
+	}{
+		{"noindex, nofollow", []string{"noindex", "nofollow"}},
+		{" NoIndex ,NOFOLLOW ", []string{"noindex", "nofollow"}},
+		{"None", []string{"noindex", "nofollow"}},
+		{"all", []string{"index", "follow"}},
+		{"max-snippet:50, , none", []string{"max-snippet:50", "noindex", "nofollow"}},
+		{"", []string{}},
+	}
+
+	for _, test := range tests {
+		if directives := parseRobotsDirectives(test.content); !slices.Equal(directives, test.expected) {
+			t.Errorf("parseRobotsDirectives(%q) = %v, want %v", test.content, directives, test.expected)
+		}
+	}
+}
+
+func TestRobotsHeaderDirectives(t *testing.T) {
+	tests := []struct {
+		values   []string
+		expected []string
+	}{
+		{[]string{"noindex"}, []string{"noindex"}},
+		{[]string{"NoIndex, nofollow"}, []string{"noindex", "nofollow"}},
+		{[]string{"googlebot: noindex"}, []string{"noindex"}},
+		{[]string{"GoogleBot:none"}, []string{"noindex", "nofollow"}},
+		{[]string{"bingbot: noindex"}, []string{}},
+		{[]string{"max-snippet:50", "noarchive"}, []string{"max-snippet:50", "noarchive"}},
+		{[]string{"noindex, unavailable_after: 25 Jun 2030 15:00:00 PST"}, []string{"noindex", "unavailable_after: 25 jun 2030 15:00:00 pst"}},
+		{[]string{"otherbot: noindex", "googlebot: nofollow"}, []string{"nofollow"}},
+		{nil, []string{}},
+	}
+
+	for _, test := range tests {
+		if directives := robotsHeaderDirectives(test.values); !slices.Equal(directives, test.expected) {
+			t.Errorf("robotsHeaderDirectives(%q) = %q, want %q", test.values, directives, test.expected)
+		}
+	}
+}
+
+func TestParsePageRobotsNone(t *testing.T) {
+	c := newCrawler("http://example.com", nil, nil, nil)
+	c.respectNofollow = true
+
+	page := c.parsePage(strings.NewReader(`<html><head><meta name="ROBOTS" content=" NONE "></head><a href="/page1">Page 1</a></html>`))
+	if !page.noindex || !page.nofollow || len(page.links) != 0 {
+		t.Errorf("Expected the page to be noindex and nofollow without links, got noindex %t, nofollow %t and links %v", page.noindex, page.nofollow, page.links)
+	}
+
+	page = c.parsePage(strings.NewReader(`<html><head><meta name="robots" content="all"></head><a href="/page1">Page 1</a></html>`))
+	if page.noindex || page.nofollow || len(page.links) != 1 {
+		t.Errorf("Expected the page to be indexed and followed, got noindex %t, nofollow %t and links %v", page.noindex, page.nofollow, page.links)
+	}
+}
+
+func TestParsePageImages(t *testing.T) {
+	c := newCrawler("http://example.com", nil, nil, nil)
+
+	htmlContent := `
+	<html>
+		<body>
+			<img src="/images/1.jpg">
+			<img src="images/2.png#top" />
+			<img src="/images/1.jpg">
+			<img src="https://cdn.example.org/3.jpg">
+			<img src="data:image/png;base64,iVBORw0KGgo=">
+			<img alt="No source">
+		</body>
+	</html>
+	`
+
+	page := c.parsePage(strings.NewReader(htmlContent))
+
+	expected := []string{"http://example.com/images/1.jpg", "http://example.com/images/2.png"}
+	if !slices.Equal(page.images, expected) {
+		t.Errorf("Expected to find images %v, got %v", expected, page.images)
+	}
+
+	c.includeExternalImages = true
+
+	page = c.parsePage(strings.NewReader(htmlContent))
+
+	expected = append(expected, "https://cdn.example.org/3.jpg")
+	if !slices.Equal(page.images, expected) {
+		t.Errorf("Expected to find images %v, got %v", expected, page.images)
+	}
+}
+
+func TestParsePageBaseHref(t *testing.T) {
+	c := newCrawler("http://example.com", nil, nil, nil)
+
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{
+			"No base",
+			`<html><body><a href="page">Page</a></body></html>`,
+			[]string{"http://example.com/page"},
+		},
+		{
+			"Absolute base",
+			`<html><head><base href="http://example.com/docs/"></head><body><a href="page">Page</a><a href="/root">Root</a></body></html>`,
+			[]string{"http://example.com/docs/page", "http://example.com/root"},
+		},
+		{
+			"Relative base",
+			`<html><head><base href="/blog/2024/"></head><body><a href="post">Post</a><a href="../2023/post">Older</a></body></html>`,
+			[]string{"http://example.com/blog/2024/post", "http://example.com/blog/2023/post"},
+		},
+		{
+			"Only the first base counts",
+			`<html><head><base href="/a/"><base href="/b/"></head><body><a href="page">Page</a></body></html>`,
+			[]string{"http://example.com/a/page"},
+		},
+		{
+			"External base",
+			`<html><head><base href="https://cdn.example.org/"></head><body><a href="page">Page</a></body></html>`,
+			[]string{},
+		},
+	}
+
+	for _, test := range tests {
+		if links := c.extractLinks(strings.NewReader(test.content)); !slices.Equal(links, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, links)
+		}
+	}
+}
+
+func TestParsePageTextChecksum(t *testing.T) {
+	c := newCrawler("http://example.com", nil, nil, nil)
+
+	page := `<html><head><title>Home</title><script>var token = "%s";</script></head>
+	<body><form><input type="hidden" name="csrf" value="%s"></form><p>%s</p></body></html>`
+
+	// Only the raw HTML is hashed by default.
+	if checksum := c.parsePage(strings.NewReader(fmt.Sprintf(page, "a", "a", "Hello"))).checksum; checksum != "" {
+		t.Errorf("Expected no text checksum, got %q", checksum)
+	}
+
+	c.changeDetectionMode = ChangeDetectionVisibleText
+
+	checksum := func(token string, text string) string {
+		return c.parsePage(strings.NewReader(fmt.Sprintf(page, token, token, text))).checksum
+	}
+
+	if checksum("a", "Hello") != checksum("b", "Hello") {
+		t.Error("Expected the checksum to ignore scripts and attributes")
+	}
+
+	if checksum("a", "Hello") != checksum("a", "\n\tHello  ") {
+		t.Error("Expected the checksum to ignore whitespace")
+	}
+
+	if checksum("a", "Hello") == checksum("a", "Goodbye") {
+		t.Error("Expected the checksum to change along with the text")
+	}
+}
+
+func TestParsePageChecksumIgnoreSelectors(t *testing.T) {
+	c := newCrawler("http://example.com", nil, nil, nil)
+	c.checksumIgnoreSelectors = []elementSelector{{tag: "script"}, {classes: []string{"counter"}}, {id: "updated"}}
+
+	page := `<html><head><script>var token = "%s";</script></head><body>
+	<div class="small counter">Visitors: <b>%s</b> <a href="/stats">Stats</a></div>
+	<p id="updated">Updated %s<br></p><div><p>%s</p></div></body></html>`
+
+	parse := func(token string, visitors string, updated string, text string) pageInfo {
+		return c.parsePage(strings.NewReader(fmt.Sprintf(page, token, visitors, updated, text)))
+	}
+
+	original := parse("a", "1", "today", "Hello")
+	if original.checksum == "" {
+		t.Fatal("Expected a checksum")
+	}
+
+	if checksum := parse("b", "2", "yesterday", "Hello").checksum; checksum != original.checksum {
+		t.Error("Expected the checksum to ignore the matching elements")
+	}
+
+	if checksum := parse("a", "1", "today", "Goodbye").checksum; checksum == original.checksum {
+		t.Error("Expected the checksum to change along with the rest of the page")
+	}
+
+	if !slices.Contains(original.links, "http://example.com/stats") {
+		t.Errorf("Expected links in ignored elements to still be found, got %v", original.links)
+	}
+
+	c.changeDetectionMode = ChangeDetectionVisibleText
+
+	if parse("a", "1", "today", "Hello").checksum != parse("a", "2", "yesterday", "Hello").checksum {
+		t.Error("Expected the text checksum to ignore the matching elements")
+	}
+}
+
+func TestParseSrcset(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"", []string{}},
+		{"image.jpg", []string{"image.jpg"}},
+		{"a.jpg 1x, b.jpg 2x", []string{"a.jpg", "b.jpg"}},
+		{"  small.jpg 480w,\n\tlarge.jpg   1080w  ", []string{"small.jpg", "large.jpg"}},
+		{"/images/a,b.jpg 1x, c.jpg 2x", []string{"/images/a,b.jpg", "c.jpg"}},
+		{"a.jpg (foo, bar) 1x, b.jpg", []string{"a.jpg", "b.jpg"}},
+		{",, a.jpg 1x,,", []string{"a.jpg"}},
+	}
+
+	for _, test := range tests {
+		if urls := parseSrcset(test.input); !slices.Equal(urls, test.expected) {
+			t.Errorf("parseSrcset(%q) = %v, want %v", test.input, urls, test.expected)
+		}
+	}
+}
+
+func TestExtractLinksSrcset(t *testing.T) {
+	c := newCrawler("http://example.com", []string{"srcset"}, nil, nil)
+
+	htmlContent := `<img srcset="/images/a.jpg 1x, /images/b.jpg 2x"><source srcset="/images/c.webp">`
+
+	expected := []string{"http://example.com/images/a.jpg", "http://example.com/images/b.jpg", "http://example.com/images/c.webp"}
+	if links := c.extractLinks(strings.NewReader(htmlContent)); !slices.Equal(links, expected) {
+		t.Errorf("Expected %v, got %v", expected, links)
+	}
+}
+
+func TestExtractLinksJSONAttributes(t *testing.T) {
+	c := newCrawler("http://example.com", nil, nil, nil)
+
+	htmlContent := `
+	<nav data-links='["/a", "/b", 42, "https://other.com/c"]'></nav>
+	<nav data-links="/d"></nav>
+	<nav data-links='["/e", '></nav>
+	`
+
+	// The attribute is ignored without opting in.
+	if links := c.extractLinks(strings.NewReader(htmlContent)); len(links) != 0 {
+		t.Errorf("Expected to find no links, got %v", links)
+	}
+
+	c.jsonLinkAttributes = []string{"data-links"}
+
+	expected := []string{"http://example.com/a", "http://example.com/b", "http://example.com/d"}
+	if links := c.extractLinks(strings.NewReader(htmlContent)); !slices.Equal(links, expected) {
+		t.Errorf("Expected %v, got %v", expected, links)
+	}
+}
+
+func BenchmarkExtractLinks(b *testing.B) {
+	c := newCrawler("http://example.com", []string{"hx-get", "data-src"}, nil, nil)
+
+	var builder strings.Builder
+	builder.WriteString(`<html><head><title>Benchmark</title><link rel="canonical" href="/"></head><body>`)
+	for i := range 2000 {
+		fmt.Fprintf(&builder, `<div class="item" id="item-%d"><a href="/page%d" class="link" title="Page %d">Page %d</a>`, i, i, i, i)
+		fmt.Fprintf(&builder, `<button hx-get="/partial%d" hx-target="#item-%d">Load</button><span>Text</span></div>`, i, i)
+	}
+	builder.WriteString(`</body></html>`)
+	htmlContent := builder.String()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		c.extractLinks(strings.NewReader(htmlContent))
+	}
+}
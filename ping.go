@@ -0,0 +1,61 @@
+package sitemapper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// searchEngineEndpoints are the well-known endpoints that search engines expose to be notified
+// of an updated sitemap. The URL of the sitemap gets appended to each of them.
+var searchEngineEndpoints = []string{
+	"https://www.google.com/ping?sitemap=",
+	"https://www.bing.com/ping?sitemap=",
+}
+
+// PingSearchEngines notifies the search engines that the sitemap at sitemapURL has been updated
+// by sending a GET request to each of their ping endpoints. The requests use the same HTTP client,
+// timeout and User-Agent as the crawler.
+//
+// An error is returned for every search engine that couldn't be notified, so an empty slice
+// means that all of them were notified successfully.
+func (mapper *SiteMapper) PingSearchEngines(sitemapURL string) []error {
+	client := mapper.spider.newHTTPClient()
+	errs := []error{}
+
+	for _, endpoint := range mapper.pingEndpoints {
+		pingURL := endpoint + url.QueryEscape(sitemapURL)
+
+		req, err := mapper.spider.newRequest(context.Background(), pingURL)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to create ping request for '%s': %w", pingURL, err))
+			continue
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to ping '%s': %w", pingURL, err))
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+			errs = append(errs, fmt.Errorf("failed to ping '%s': status code %d", pingURL, resp.StatusCode))
+		}
+	}
+
+	return errs
+}
+
+// autoPing pings the search engines with the sitemap URL set through SetAutoPing, if any, and
+// logs every error.
+func (mapper *SiteMapper) autoPing() {
+	if mapper.autoPingURL == "" {
+		return
+	}
+
+	for _, err := range mapper.PingSearchEngines(mapper.autoPingURL) {
+		mapper.spider.errorLogger(err)
+	}
+}
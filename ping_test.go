@@ -0,0 +1,120 @@
+package sitemapper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPingSearchEngines(t *testing.T) {
+	var userAgent atomic.Value
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ok", func(w http.ResponseWriter, r *http.Request) {
+		userAgent.Store(r.Header.Get("User-Agent"))
+
+		if sitemap := r.URL.Query().Get("sitemap"); sitemap != "https://example.com/sitemap.xml" {
+			t.Errorf("Expected sitemap to be 'https://example.com/sitemap.xml', got '%s'", sitemap)
+		}
+	})
+	mux.HandleFunc("GET /fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	mapper := newTestSiteMapper()
+	mapper.pingEndpoints = []string{mockServer.URL + "/ok?sitemap=", mockServer.URL + "/fail?sitemap="}
+
+	errs := mapper.PingSearchEngines("https://example.com/sitemap.xml")
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 error, got %v", errs)
+	}
+
+	if ua, _ := userAgent.Load().(string); ua != DefaultUserAgent {
+		t.Errorf("Expected User-Agent to be '%s', got '%s'", DefaultUserAgent, ua)
+	}
+}
+
+func TestAutoPing(t *testing.T) {
+	var pings atomic.Int32
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pings.Add(1)
+	}))
+	defer mockServer.Close()
+
+	mapper := newTestSiteMapper()
+	mapper.pingEndpoints = []string{mockServer.URL + "/?sitemap="}
+
+	// Nothing should be pinged until a sitemap URL has been set.
+	mapper.autoPing()
+
+	mapper.autoPingURL = "https://example.com/sitemap.xml"
+	mapper.autoPing()
+
+	if n := pings.Load(); n != 1 {
+		t.Errorf("Expected 1 ping, got %d", n)
+	}
+}
+
+func TestAutoPingSkipsFailedCrawls(t *testing.T) {
+	var pings atomic.Int32
+
+	pingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pings.Add(1)
+	}))
+	defer pingServer.Close()
+
+	siteServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body>Home</body></html>`))
+	}))
+	defer siteServer.Close()
+
+	crawls := make(chan struct{}, 10)
+
+	options := DefaultOptions()
+
+	if err := options.SetDomain(siteServer.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := options.SetAutoPing("https://example.com/sitemap.xml"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := options.SetCrawlInterval(time.Millisecond * 10); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first crawl uses up the budget so the scheduled crawls fail.
+	if err := options.SetRequestBudget(1); err != nil {
+		t.Fatal(err)
+	}
+
+	options.SetBlockUntilFirstCrawl(true)
+	options.SetCallbackFunction(func(*SiteMapper) {
+		crawls <- struct{}{}
+	})
+
+	mapper := newSiteMapper(options)
+	mapper.pingEndpoints = []string{pingServer.URL + "/?sitemap="}
+	mapper.start()
+	defer mapper.Stop()
+
+	// The goroutine is done with the first scheduled crawl once the second one has finished.
+	for range 2 {
+		select {
+		case <-crawls:
+		case <-time.After(time.Second * 5):
+			t.Fatal("Timed out waiting for the scheduled crawls")
+		}
+	}
+
+	if n := pings.Load(); n != 1 {
+		t.Errorf("Expected only the first crawl to ping, got %d pings", n)
+	}
+}
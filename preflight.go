@@ -0,0 +1,53 @@
+package sitemapper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// preflight checks whether the SiteMapper's starting URL can be reached. It returns nil
+// unless the preflight check and the blocking first crawl are both turned on.
+func (mapper *SiteMapper) preflight(options *SiteMapperOptions) error {
+	if !options.preflightCheck || !options.blockUntilFirstCrawl {
+		return nil
+	}
+
+	return mapper.spider.preflight(context.Background(), mapper.startingURL)
+}
+
+// preflight sends a HEAD request to the given URL with the same client, headers and credentials
+// a crawl would use. The request counts against the request budget like any other. Only failing
+// to get a response counts as unreachable, so error pages and redirects are fine. Pages loaded by
+// a page fetcher aren't checked since the crawler doesn't know how the fetcher reaches them.
+func (crawler *crawler) preflight(ctx context.Context, url string) error {
+	if crawler.pageFetcher != nil {
+		return nil
+	}
+
+	link, ok := crawler.normalizeURL(url)
+	if !ok {
+		return fmt.Errorf("%w: %q is not within the domain", ErrUnreachable, url)
+	}
+
+	req, err := crawler.newRequest(ctx, link)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnreachable, err)
+	}
+
+	req.Method = http.MethodHead
+
+	client := crawler.newHTTPClient()
+	client.Transport = &budgetTransport{crawler: crawler, base: client.Transport}
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnreachable, err)
+	}
+	resp.Body.Close()
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package sitemapper
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewSiteMapperPreflightCheck(t *testing.T) {
+	var mutex sync.Mutex
+	var methods []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		methods = append(methods, r.Method)
+		mutex.Unlock()
+
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	options := DefaultOptions()
+
+	if err := options.SetDomain(mockServer.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := options.SetDurationBeforeFirstCrawl(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	options.SetBlockUntilFirstCrawl(true)
+	options.SetPreflightCheck(true)
+
+	// Any response means the domain can be reached, even an error page.
+	mapper, err := NewSiteMapperWithError(options)
+	if err != nil {
+		t.Fatalf("Expected a reachable domain to pass the preflight check, got %v", err)
+	}
+	mapper.Stop()
+
+	// The preflight request counts towards the requests the SiteMapper has sent.
+	if used := mapper.RequestsUsed(); used != 2 {
+		t.Errorf("Expected the preflight check and the first crawl to use 2 requests, got %d", used)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(methods) == 0 || methods[0] != http.MethodHead {
+		t.Errorf("Expected the preflight check to send a HEAD request first, got %v", methods)
+	}
+
+	// A closed server refuses the connection.
+	mockServer.Close()
+
+	mapper, err = NewSiteMapperWithError(options)
+	if err == nil {
+		mapper.Stop()
+		t.Fatal("Expected an error for an unreachable domain, got nil")
+	}
+
+	if !errors.Is(err, ErrUnreachable) {
+		t.Errorf("Expected the error to wrap ErrUnreachable, got %v", err)
+	}
+
+	// Without the blocking first crawl there is nothing to check. The options are rebuilt since
+	// the first SiteMapper is still running with the previous ones.
+	options = DefaultOptions()
+
+	if err := options.SetDomain(mockServer.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := options.SetDurationBeforeFirstCrawl(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	options.SetPreflightCheck(true)
+
+	mapper, err = NewSiteMapperWithError(options)
+	if err != nil {
+		t.Fatalf("Expected no preflight check without the blocking first crawl, got %v", err)
+	}
+	mapper.Stop()
+}
+
+func TestNewSiteMapperPreflightCheckLogs(t *testing.T) {
+	mockServer := httptest.NewServer(http.NotFoundHandler())
+	mockServer.Close()
+
+	var logged []error
+
+	options := DefaultOptions()
+
+	if err := options.SetDomain(mockServer.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	options.SetBlockUntilFirstCrawl(true)
+	options.SetPreflightCheck(true)
+	options.SetErrorLogger(func(err error) {
+		logged = append(logged, err)
+	})
+
+	mapper := NewSiteMapper(options)
+	mapper.Stop()
+
+	if len(logged) == 0 || !errors.Is(logged[0], ErrUnreachable) {
+		t.Errorf("Expected the preflight error to be logged first, got %v", logged)
+	}
+}
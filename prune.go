@@ -0,0 +1,34 @@
+package sitemapper
+
+import "time"
+
+// PruneStaleLinks removes the links that haven't been fetched successfully for longer than maxAge
+// and returns how many were removed. A complete crawl only keeps the pages it found, but the pages
+// kept from a cancelled crawl or loaded with LoadState stay around until a crawl finds them again.
+// Pruning makes sure that deleted pages eventually fall out of the sitemap. The links that were
+// added with AddURL are never pruned. Example:
+//
+//	mapper.PruneStaleLinks(time.Hour * 24 * 30)
+func (mapper *SiteMapper) PruneStaleLinks(maxAge time.Duration) int {
+	return mapper.spider.pruneStaleLinks(time.Now().Add(-maxAge))
+}
+
+// pruneStaleLinks removes the links that were last seen before the cutoff.
+func (crawler *crawler) pruneStaleLinks(cutoff time.Time) int {
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	pruned := 0
+	for link, url := range crawler.links {
+		if !url.manual && url.lastSeen.Before(cutoff) {
+			delete(crawler.links, link)
+			pruned++
+		}
+	}
+
+	if pruned > 0 {
+		crawler.metrics.SetKnownLinks(len(crawler.links))
+	}
+
+	return pruned
+}
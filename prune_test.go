@@ -0,0 +1,32 @@
+package sitemapper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPruneStaleLinks(t *testing.T) {
+	now := time.Now()
+
+	c := newCrawler("http://example.com", nil, func(string) {}, func(error) {})
+	c.links = map[string]crawlerURL{
+		"http://example.com":       {link: "http://example.com", lastSeen: now},
+		"http://example.com/old":   {link: "http://example.com/old", lastSeen: now.Add(-time.Hour * 48)},
+		"http://example.com/older": {link: "http://example.com/older", lastSeen: now.Add(-time.Hour * 72)},
+	}
+
+	mapper := &SiteMapper{spider: c}
+
+	if pruned := mapper.PruneStaleLinks(time.Hour * 96); pruned != 0 {
+		t.Errorf("Expected no links to be pruned, got %d", pruned)
+	}
+
+	if pruned := mapper.PruneStaleLinks(time.Hour * 24); pruned != 2 {
+		t.Errorf("Expected 2 links to be pruned, got %d", pruned)
+	}
+
+	links := c.getLinks()
+	if len(links) != 1 || links[0].link != "http://example.com" {
+		t.Errorf("Expected only the recently seen link to remain, got %v", links)
+	}
+}
@@ -0,0 +1,114 @@
+package sitemapper
+
+import (
+	"slices"
+	"sync"
+)
+
+// crawlItem is a URL waiting in the crawl queue.
+type crawlItem struct {
+	// link is the normalized URL that should be crawled.
+	link string
+
+	// depth is the number of links that were followed from the starting URL to reach this URL.
+	depth int
+
+	// referrer is the page on which the URL was found. It's empty for the URLs the crawl
+	// started from.
+	referrer string
+}
+
+// crawlQueue is a FIFO queue of URLs that is shared between the crawl workers.
+//
+// A worker that pops a URL is considered in flight until it calls done. The queue is
+// only considered drained once it is empty and no URL is in flight, since a worker
+// could still push newly discovered URLs.
+type crawlQueue struct {
+	// cond is used to wake up workers that are waiting for URLs.
+	cond *sync.Cond
+
+	// items are the URLs that still need to be processed.
+	items []crawlItem
+
+	// inFlight are the URLs that workers are currently processing.
+	inFlight []crawlItem
+
+	// closed is set once the queue has been closed. A closed queue doesn't hand out any
+	// more URLs.
+	closed bool
+}
+
+// newCrawlQueue creates a new crawlQueue containing the given URLs.
+func newCrawlQueue(items ...crawlItem) *crawlQueue {
+	return &crawlQueue{
+		cond:  sync.NewCond(&sync.Mutex{}),
+		items: items,
+	}
+}
+
+// push adds URLs to the back of the queue and wakes up any waiting workers.
+func (queue *crawlQueue) push(items ...crawlItem) {
+	if len(items) == 0 {
+		return
+	}
+
+	queue.cond.L.Lock()
+	defer queue.cond.L.Unlock()
+
+	queue.items = append(queue.items, items...)
+	queue.cond.Broadcast()
+}
+
+// pop removes the URL at the front of the queue. It blocks until a URL is available
+// and returns false once the queue has been drained or closed.
+func (queue *crawlQueue) pop() (crawlItem, bool) {
+	queue.cond.L.Lock()
+	defer queue.cond.L.Unlock()
+
+	for !queue.closed && len(queue.items) == 0 && len(queue.inFlight) > 0 {
+		queue.cond.Wait()
+	}
+
+	if queue.closed || len(queue.items) == 0 {
+		return crawlItem{}, false
+	}
+
+	item := queue.items[0]
+	queue.items = queue.items[1:]
+	queue.inFlight = append(queue.inFlight, item)
+
+	return item, true
+}
+
+// done marks the URL previously returned by pop as processed.
+func (queue *crawlQueue) done(item crawlItem) {
+	queue.cond.L.Lock()
+	defer queue.cond.L.Unlock()
+
+	if i := slices.Index(queue.inFlight, item); i >= 0 {
+		queue.inFlight = slices.Delete(queue.inFlight, i, i+1)
+	}
+
+	// Wake up the waiting workers so that they can exit if the queue has been drained.
+	if len(queue.inFlight) == 0 && len(queue.items) == 0 {
+		queue.cond.Broadcast()
+	}
+}
+
+// snapshot returns the URLs that haven't been processed yet, including the ones that workers
+// are currently processing.
+func (queue *crawlQueue) snapshot() []crawlItem {
+	queue.cond.L.Lock()
+	defer queue.cond.L.Unlock()
+
+	return slices.Concat(queue.inFlight, queue.items)
+}
+
+// close stops the queue from handing out any more URLs and wakes up all waiting workers.
+func (queue *crawlQueue) close() {
+	queue.cond.L.Lock()
+	defer queue.cond.L.Unlock()
+
+	queue.closed = true
+	queue.cond.Broadcast()
+}
@@ -0,0 +1,38 @@
+package sitemapper
+
+import "slices"
+
+// Referrers returns the pages that linked to the given URL during the latest crawl, sorted. This
+// shows how a URL was discovered, which is useful for tracking down orphaned or unexpected URLs.
+// The URL is normalized the same way the crawler normalizes the links it finds, so it doesn't have
+// to match exactly. Nil is returned if no page linked to it.
+func (mapper *SiteMapper) Referrers(url string) []string {
+	return mapper.spider.getReferrers(url)
+}
+
+// getReferrers retrieves the pages that linked to the given URL during the latest crawl.
+func (crawler *crawler) getReferrers(link string) []string {
+	normalizedURL, ok := crawler.normalizeURL(link)
+	if !ok {
+		return nil
+	}
+
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	return slices.Clone(crawler.linkReferrers[normalizedURL])
+}
+
+// recordReferrer records that the referrer links to the given URL during the current crawl. The
+// caller must hold the mutex.
+func (crawler *crawler) recordReferrer(link string, referrer string) {
+	if link == referrer {
+		return
+	}
+
+	if crawler.referrers[link] == nil {
+		crawler.referrers[link] = make(map[string]struct{})
+	}
+
+	crawler.referrers[link][referrer] = struct{}{}
+}
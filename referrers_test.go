@@ -0,0 +1,45 @@
+package sitemapper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCrawlReferrers(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/about">About</a><a href="/contact">Contact</a><a href="/">Home</a>`))
+	})
+	mux.HandleFunc("GET /about", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/contact">Contact</a><a href="/contact">Contact again</a>`))
+	})
+	mux.HandleFunc("GET /contact", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/about">About</a>`))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	c.crawl(context.Background(), "/")
+
+	tests := []struct {
+		url      string
+		expected []string
+	}{
+		{"/about", []string{mockServer.URL, mockServer.URL + "/contact"}},
+		{mockServer.URL + "/contact", []string{mockServer.URL, mockServer.URL + "/about"}},
+		{"/", nil},
+		{"/unknown", nil},
+		{"https://example.com/about", nil},
+	}
+
+	for _, test := range tests {
+		if referrers := c.getReferrers(test.url); !reflect.DeepEqual(referrers, test.expected) {
+			t.Errorf("Expected referrers of %q to be %v, got %v", test.url, test.expected, referrers)
+		}
+	}
+}
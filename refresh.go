@@ -0,0 +1,63 @@
+package sitemapper
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// RefreshHandler returns an HTTP handler that triggers a recrawl of the site with RecrawlSite,
+// so that a webhook like a CMS publish event can refresh the sitemap right away instead of
+// waiting for the next scheduled crawl. Example:
+//
+//	http.Handle("POST /sitemap/refresh", mapper.RefreshHandler())
+//
+// The handler only accepts POST requests. If a refresh secret has been set with
+// SetRefreshSecret, the request has to send it as a bearer token in the Authorization header.
+// It responds with:
+//
+//   - 202 Accepted when the recrawl has been triggered.
+//   - 401 Unauthorized when the secret is missing or wrong.
+//   - 405 Method Not Allowed for anything but POST.
+//   - 429 Too Many Requests when the site is already being crawled.
+//   - 503 Service Unavailable once the SiteMapper has been stopped.
+func (mapper *SiteMapper) RefreshHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !mapper.authorizedRefresh(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		err := mapper.RecrawlSite()
+		switch {
+		case err == nil:
+			w.WriteHeader(http.StatusAccepted)
+		case errors.Is(err, ErrCrawlInProgress):
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		default:
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		}
+	}
+}
+
+// authorizedRefresh checks whether the request sent the refresh secret as a bearer token.
+func (mapper *SiteMapper) authorizedRefresh(r *http.Request) bool {
+	if mapper.refreshSecret == "" {
+		return true
+	}
+
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(mapper.refreshSecret)) == 1
+}
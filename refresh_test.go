@@ -0,0 +1,68 @@
+package sitemapper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRefreshHandler(t *testing.T) {
+	mapper := &SiteMapper{
+		recrawlSignal: make(chan bool),
+		done:          make(chan struct{}),
+		refreshSecret: "secret",
+	}
+
+	handler := mapper.RefreshHandler()
+
+	refresh := func(method string, authorization string) int {
+		req := httptest.NewRequest(method, "/refresh", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+
+		recorder := httptest.NewRecorder()
+		handler(recorder, req)
+
+		return recorder.Code
+	}
+
+	if code := refresh(http.MethodGet, "Bearer secret"); code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected a GET request to get %d, got %d", http.StatusMethodNotAllowed, code)
+	}
+
+	if code := refresh(http.MethodPost, ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected a request without the secret to get %d, got %d", http.StatusUnauthorized, code)
+	}
+
+	if code := refresh(http.MethodPost, "Bearer wrong"); code != http.StatusUnauthorized {
+		t.Errorf("Expected a request with the wrong secret to get %d, got %d", http.StatusUnauthorized, code)
+	}
+
+	// Nothing is receiving the recrawl signal, just like when a crawl is running.
+	if code := refresh(http.MethodPost, "Bearer secret"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected a request during a crawl to get %d, got %d", http.StatusTooManyRequests, code)
+	}
+
+	received := make(chan struct{})
+	go func() {
+		<-mapper.recrawlSignal
+		close(received)
+	}()
+
+	code := refresh(http.MethodPost, "Bearer secret")
+	for code == http.StatusTooManyRequests {
+		// Wait for the goroutine to be ready to receive the signal.
+		code = refresh(http.MethodPost, "Bearer secret")
+	}
+
+	if code != http.StatusAccepted {
+		t.Errorf("Expected the refresh to be accepted with %d, got %d", http.StatusAccepted, code)
+	}
+	<-received
+
+	close(mapper.done)
+	if code := refresh(http.MethodPost, "Bearer secret"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected a request after Stop to get %d, got %d", http.StatusServiceUnavailable, code)
+	}
+}
@@ -0,0 +1,263 @@
+package sitemapper
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// robotsRule is a single Allow or Disallow rule from a robots.txt file.
+type robotsRule struct {
+	// allow is true for Allow rules and false for Disallow rules.
+	allow bool
+
+	// pattern is the path pattern of the rule. It may contain "*" wildcards and
+	// end with "$" to anchor the match to the end of the path.
+	pattern string
+
+	// matcher is the compiled form of pattern.
+	matcher *regexp.Regexp
+}
+
+// robotsRules holds the robots.txt rules that apply to the crawler.
+type robotsRules struct {
+	rules []robotsRule
+
+	// sitemaps are the URLs of the sitemaps listed by Sitemap directives. They apply to every
+	// user-agent.
+	sitemaps []string
+
+	// crawlDelay is the minimum delay between requests requested by the site.
+	crawlDelay time.Duration
+}
+
+// robotsGroup is a group of rules that apply to one or more user-agents.
+type robotsGroup struct {
+	userAgents []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+
+	// hasDirectives is set once the group contains anything other than user-agent lines.
+	hasDirectives bool
+}
+
+// parseRobotsTxt parses a robots.txt file and returns the rules that apply to the given
+// user-agent. A group applies if its user-agent is contained in the given user-agent,
+// ignoring case. If no group applies the rules for "*" are used instead.
+//
+// Malformed lines are skipped. The first one encountered is reported through the returned
+// error, alongside the rules that could still be parsed.
+func parseRobotsTxt(r io.Reader, userAgent string) (*robotsRules, error) {
+	var groups []*robotsGroup
+	var current *robotsGroup
+	var parseErr error
+	var sitemaps []string
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+
+		// Strip comments and surrounding whitespace.
+		line := scanner.Text()
+		if index := strings.Index(line, "#"); index >= 0 {
+			line = line[:index]
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			if parseErr == nil {
+				parseErr = fmt.Errorf("invalid robots.txt line %d: %q", lineNumber, line)
+			}
+
+			continue
+		}
+
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// Consecutive user-agent lines belong to the same group.
+			if current == nil || current.hasDirectives {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+			}
+
+			current.userAgents = append(current.userAgents, strings.ToLower(value))
+		case "allow", "disallow":
+			if current == nil {
+				if parseErr == nil {
+					parseErr = fmt.Errorf("invalid robots.txt line %d: rule outside of a user-agent group", lineNumber)
+				}
+
+				continue
+			}
+
+			current.hasDirectives = true
+
+			// An empty Disallow means everything is allowed so there is nothing to record.
+			if value == "" {
+				continue
+			}
+
+			current.rules = append(current.rules, robotsRule{
+				allow:   key == "allow",
+				pattern: value,
+				matcher: compileRobotsPattern(value),
+			})
+		case "crawl-delay":
+			if current == nil {
+				if parseErr == nil {
+					parseErr = fmt.Errorf("invalid robots.txt line %d: crawl-delay outside of a user-agent group", lineNumber)
+				}
+
+				continue
+			}
+
+			current.hasDirectives = true
+
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds < 0 {
+				if parseErr == nil {
+					parseErr = fmt.Errorf("invalid robots.txt line %d: invalid crawl-delay %q", lineNumber, value)
+				}
+
+				continue
+			}
+
+			current.crawlDelay = time.Duration(seconds * float64(time.Second))
+		case "sitemap":
+			// Sitemap directives aren't part of any group.
+			if value != "" {
+				sitemaps = append(sitemaps, value)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return &robotsRules{sitemaps: sitemaps}, fmt.Errorf("failed to read robots.txt: %w", err)
+	}
+
+	userAgent = strings.ToLower(userAgent)
+
+	// Prefer the groups that specifically target our user-agent over the wildcard group.
+	rules := &robotsRules{}
+	wildcard := &robotsRules{}
+	matched := false
+
+	for _, group := range groups {
+		for _, agent := range group.userAgents {
+			if agent == "*" {
+				wildcard.rules = append(wildcard.rules, group.rules...)
+				wildcard.crawlDelay = max(wildcard.crawlDelay, group.crawlDelay)
+			} else if strings.Contains(userAgent, agent) {
+				rules.rules = append(rules.rules, group.rules...)
+				rules.crawlDelay = max(rules.crawlDelay, group.crawlDelay)
+				matched = true
+			}
+		}
+	}
+
+	if !matched {
+		rules = wildcard
+	}
+
+	rules.sitemaps = sitemaps
+
+	return rules, parseErr
+}
+
+// allowed reports whether the crawler is allowed to fetch the given URL. The most
+// specific (longest) matching rule wins, and Allow wins when rules are equally specific.
+func (robots *robotsRules) allowed(link string) bool {
+	if robots == nil {
+		return true
+	}
+
+	parsedURL, err := url.Parse(link)
+	if err != nil {
+		return true
+	}
+
+	path := parsedURL.RequestURI()
+
+	allowed := true
+	longest := -1
+
+	for _, rule := range robots.rules {
+		if !rule.matcher.MatchString(path) {
+			continue
+		}
+
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			longest = len(rule.pattern)
+			allowed = rule.allow
+		}
+	}
+
+	return allowed
+}
+
+// compileRobotsPattern converts a robots.txt path pattern into a regular expression. A "*"
+// matches any sequence of characters and a trailing "$" anchors the match to the end of the path.
+func compileRobotsPattern(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+
+	return regexp.MustCompile(expr)
+}
+
+// fetchRobotsTxt fetches and parses the robots.txt file of the crawler's domain. A missing
+// robots.txt means that everything is allowed.
+func (crawler *crawler) fetchRobotsTxt(ctx context.Context, client *http.Client) *robotsRules {
+	robotsURL := crawler.domain + "/robots.txt"
+
+	req, err := crawler.newRequest(ctx, robotsURL)
+	if err != nil {
+		crawler.logCrawlError(robotsURL, fmt.Errorf("error creating request for \"%s\": %w", robotsURL, err))
+		return nil
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		crawler.logCrawlError(robotsURL, fmt.Errorf("error fetching \"%s\": %w", robotsURL, err))
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		crawler.infoLogger(fmt.Sprintf("No robots.txt found at '%s' (status code %d)", robotsURL, resp.StatusCode))
+		return nil
+	}
+
+	rules, err := parseRobotsTxt(resp.Body, crawler.userAgent)
+	if err != nil {
+		crawler.logCrawlError(robotsURL, fmt.Errorf("error parsing \"%s\": %w", robotsURL, err))
+	}
+
+	return rules
+}
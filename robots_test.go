@@ -0,0 +1,153 @@
+package sitemapper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRobotsTxt(t *testing.T) {
+	robotsTxt := `
+	# Comments should be ignored.
+	User-agent: *
+	Disallow: /private
+	Allow: /private/public
+	Disallow: /*.pdf$
+
+	User-agent: otherbot
+	Disallow: /
+	`
+
+	rules, err := parseRobotsTxt(strings.NewReader(robotsTxt), DefaultUserAgent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"http://example.com/", true},
+		{"http://example.com/page", true},
+		{"http://example.com/private", false},
+		{"http://example.com/private/secret", false},
+		{"http://example.com/private/public", true},
+		{"http://example.com/files/report.pdf", false},
+		{"http://example.com/files/report.pdf?download=1", true},
+	}
+
+	for _, test := range tests {
+		if allowed := rules.allowed(test.input); allowed != test.expected {
+			t.Errorf("Expected allowed(%q) to be %v, got %v", test.input, test.expected, allowed)
+		}
+	}
+}
+
+func TestParseRobotsTxtSpecificUserAgent(t *testing.T) {
+	robotsTxt := `
+	User-agent: *
+	Disallow: /
+
+	User-agent: Sitemapper
+	Disallow: /admin
+	`
+
+	rules, err := parseRobotsTxt(strings.NewReader(robotsTxt), DefaultUserAgent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !rules.allowed("http://example.com/page") {
+		t.Error("Expected the sitemapper group to take precedence over the wildcard group")
+	}
+
+	if rules.allowed("http://example.com/admin") {
+		t.Error("Expected '/admin' to be disallowed")
+	}
+}
+
+func TestParseRobotsTxtCrawlDelay(t *testing.T) {
+	robotsTxt := `
+	User-agent: *
+	Crawl-delay: 2.5
+	Disallow: /private
+	`
+
+	rules, err := parseRobotsTxt(strings.NewReader(robotsTxt), DefaultUserAgent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rules.crawlDelay != time.Millisecond*2500 {
+		t.Errorf("Expected crawl delay to be 2.5s, got %v", rules.crawlDelay)
+	}
+}
+
+func TestParseRobotsTxtSitemaps(t *testing.T) {
+	robotsTxt := `
+	Sitemap: http://example.com/sitemap.xml
+
+	User-agent: otherbot
+	Disallow: /
+	Sitemap: http://example.com/sitemap-news.xml
+	`
+
+	rules, err := parseRobotsTxt(strings.NewReader(robotsTxt), DefaultUserAgent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sitemap directives apply to every user-agent.
+	expected := []string{"http://example.com/sitemap.xml", "http://example.com/sitemap-news.xml"}
+	if !slices.Equal(rules.sitemaps, expected) {
+		t.Errorf("Expected sitemaps %v, got %v", expected, rules.sitemaps)
+	}
+}
+
+func TestParseRobotsTxtInvalidLine(t *testing.T) {
+	robotsTxt := `
+	User-agent: *
+	this line is invalid
+	Disallow: /private
+	`
+
+	rules, err := parseRobotsTxt(strings.NewReader(robotsTxt), DefaultUserAgent)
+	if err == nil {
+		t.Error("Expected an error for the invalid line")
+	}
+
+	if rules.allowed("http://example.com/private") {
+		t.Error("Expected the rules after the invalid line to still be parsed")
+	}
+}
+
+func TestCrawlRespectRobotsTxt(t *testing.T) {
+	mux := createMockServer()
+	mux.HandleFunc("GET /robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("User-agent: *\nDisallow: /page2\n"))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, []string{"hx-get"}, func(string) {}, func(error) {})
+	c.respectRobotsTxt = true
+	c.crawl(context.Background(), "/")
+
+	linksFound := []string{}
+	for _, link := range c.getLinks() {
+		linksFound = append(linksFound, link.link)
+	}
+
+	if slices.Contains(linksFound, mockServer.URL+"/page2") {
+		t.Error("Crawler found '/page2' even though robots.txt disallows it")
+	}
+
+	if !slices.Contains(linksFound, mockServer.URL+"/page1") {
+		t.Error("Crawler did not find '/page1'")
+	}
+}
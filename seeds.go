@@ -0,0 +1,46 @@
+package sitemapper
+
+import "fmt"
+
+// SeedURLs queues pages to be crawled at the start of the next crawl, even if nothing links to
+// them. Unlike AddURL, the pages are fetched and hashed like any page the crawler finds, so they
+// only end up in the sitemap if they can be crawled, and the links on them are followed. This is
+// useful for incremental crawls where the valid URLs are already known. The URLs can be absolute
+// or relative to the domain and are normalized the same way the links the crawler finds are.
+//
+// An error is returned if any of the URLs is outside of the domain, in which case none of them
+// are queued. The URLs are only crawled once, by the next crawl.
+func (mapper *SiteMapper) SeedURLs(urls []string) error {
+	return mapper.spider.seedURLs(urls)
+}
+
+// seedURLs normalizes the URLs and stores them until the next crawl takes them.
+func (crawler *crawler) seedURLs(urls []string) error {
+	links := make([]string, 0, len(urls))
+	for _, rawURL := range urls {
+		link, ok := crawler.normalizeURL(rawURL)
+		if !ok {
+			return fmt.Errorf("invalid URL: %q is not within the domain", rawURL)
+		}
+
+		links = append(links, link)
+	}
+
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	crawler.seeds = append(crawler.seeds, links...)
+
+	return nil
+}
+
+// takeSeeds returns the seeded URLs and forgets about them, so that they're only crawled once.
+func (crawler *crawler) takeSeeds() []string {
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	seeds := crawler.seeds
+	crawler.seeds = nil
+
+	return seeds
+}
@@ -0,0 +1,68 @@
+package sitemapper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSeedURLs(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/about">About</a>`))
+	})
+	mux.HandleFunc("GET /about", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<h1>About</h1>`))
+	})
+	mux.HandleFunc("GET /orphan", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/orphan/child">Child</a>`))
+	})
+	mux.HandleFunc("GET /orphan/child", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<h1>Child</h1>`))
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	c := newCrawler(mockServer.URL, nil, func(string) {}, func(error) {})
+	mapper := &SiteMapper{spider: c, domain: mockServer.URL}
+
+	expectedErr := errors.New(`invalid URL: "https://example.com/page" is not within the domain`)
+	if err := mapper.SeedURLs([]string{"/orphan", "https://example.com/page"}); err == nil || err.Error() != expectedErr.Error() {
+		t.Errorf("Expected error: %v, got: %v", expectedErr, err)
+	}
+
+	if err := mapper.SeedURLs([]string{"/orphan", "/missing"}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	links := func() []string {
+		links := []string{}
+		for _, link := range c.getLinks() {
+			links = append(links, strings.TrimPrefix(link.link, mockServer.URL))
+		}
+
+		slices.Sort(links)
+		return links
+	}
+
+	c.crawl(context.Background(), "/")
+
+	// The seeded page and the pages it links to are crawled, while the broken seed isn't recorded.
+	expected := []string{"", "/about", "/orphan", "/orphan/child"}
+	if found := links(); !slices.Equal(found, expected) {
+		t.Errorf("Expected to find %v, got %v", expected, found)
+	}
+
+	// The seeds are only crawled once.
+	c.crawl(context.Background(), "/")
+
+	expected = []string{"", "/about"}
+	if found := links(); !slices.Equal(found, expected) {
+		t.Errorf("Expected to find %v after the next crawl, got %v", expected, found)
+	}
+}
@@ -1,17 +1,64 @@
 package sitemapper
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+// maxSitemapURLs is the maximum number of URLs a single sitemap file may contain according
+// to the sitemap protocol.
+const maxSitemapURLs = 50000
+
+// parallelFilterThreshold is the number of links from which they're filtered and turned into
+// sitemap URLs by multiple goroutines. Below it the goroutines cost more than they save.
+const parallelFilterThreshold = 2048
+
+// defaultLastModFormat is the time layout used for <lastmod> when no other layout has been set.
+const defaultLastModFormat = "2006-01-02"
+
+// xmlDeclaration is the XML declaration that starts every sitemap.
+const xmlDeclaration = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
+
+// defaultSitemapIndent is the indentation used for each level of the sitemaps by default.
+const defaultSitemapIndent = "\t"
+
 type sitemapURL struct {
 	XMLName      xml.Name `xml:"url"`
 	Location     string   `xml:"loc"`
 	LastModified string   `xml:"lastmod,omitempty"`
+	ChangeFreq   string   `xml:"changefreq,omitempty"`
+	Priority     string   `xml:"priority,omitempty"`
+}
+
+// jsonSitemapURL is the JSON representation of a sitemapURL.
+type jsonSitemapURL struct {
+	Location     string `json:"loc"`
+	LastModified string `json:"lastmod,omitempty"`
+	ChangeFreq   string `json:"changefreq,omitempty"`
+	Priority     string `json:"priority,omitempty"`
+}
+
+type sitemapIndexEntry struct {
+	XMLName      xml.Name `xml:"sitemap"`
+	Location     string   `xml:"loc"`
+	LastModified string   `xml:"lastmod,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name            `xml:"sitemapindex"`
+	Xmlns    string              `xml:"xmlns,attr"`
+	Sitemaps []sitemapIndexEntry `xml:"sitemap"`
 }
 
 type sitemapURLSet struct {
@@ -22,59 +69,518 @@ type sitemapURLSet struct {
 	URLS         []sitemapURL `xml:"url"`
 }
 
+// sitemapFilter decides which of the crawled links end up in the sitemap.
+type sitemapFilter struct {
+	// include are the patterns of which a link has to match at least one. Every link is
+	// included if there are none.
+	include []*regexp.Regexp
+
+	// exclude are the patterns that a link must not match.
+	exclude []*regexp.Regexp
+}
+
+// GenerateSitemap generates the sitemap, replacing the crawled domain with baseDomain.
+//
+// The filterPattern is a regex of the URLs to leave out of the sitemap: every URL that matches
+// it is excluded. Keep in mind that an empty pattern matches every URL. The matching URLs are
+// the only ones that are included instead when the filter mode was set to FilterInclude with
+// SetFilterMode. Use GenerateSitemapFiltered to filter on multiple patterns.
+//
+// If an error occurs a valid sitemap without any URLs is returned along with it.
 func (mapper *SiteMapper) GenerateSitemap(baseDomain string, filterPattern string) (string, error) {
-	urlSet := sitemapURLSet{
-		Xmlns:        "http://www.sitemaps.org/schemas/sitemap/0.9",
-		XmlnsXsi:     "http://www.w3.org/2001/XMLSchema-instance",
-		XsiSchemaLoc: "http://www.sitemaps.org/schemas/sitemap/0.9 http://www.sitemaps.org/schemas/sitemap/0.9/sitemap.xsd",
+	urls, err := mapper.sitemapURLs(baseDomain, filterPattern)
+	if err != nil {
+		return mapper.EmptySitemapXML(baseDomain), err
 	}
 
-	links := mapper.spider.getLinks()
+	sitemap, err := mapper.marshalURLSet(urls)
+	if err != nil {
+		return mapper.EmptySitemapXML(baseDomain), err
+	}
 
-	var urls []sitemapURL
+	return sitemap, nil
+}
+
+// GenerateSitemapFiltered generates the sitemap the same way GenerateSitemap does, but filters the
+// URLs on multiple regex patterns. A URL is only included when it matches at least one of the
+// include patterns, and then only if it doesn't match any of the exclude patterns. Every URL
+// passes the include step when there are no include patterns, so passing nil for both includes
+// every URL.
+//
+//	sitemap, err := mapper.GenerateSitemapFiltered("https://example.com", []string{"/htmx", "/admin"}, []string{"^https://example.com/blog"})
+func (mapper *SiteMapper) GenerateSitemapFiltered(baseDomain string, exclude []string, include []string) (string, error) {
+	filter, err := newSitemapFilter(exclude, include)
+	if err != nil {
+		return mapper.EmptySitemapXML(baseDomain), err
+	}
+
+	sitemap, err := mapper.marshalURLSet(mapper.filteredSitemapURLs(baseDomain, filter))
+	if err != nil {
+		return mapper.EmptySitemapXML(baseDomain), err
+	}
+
+	return sitemap, nil
+}
 
-	filter, err := regexp.Compile(filterPattern)
+// WriteSitemap generates the same sitemap as GenerateSitemap but streams it straight into w,
+// which avoids holding the entire sitemap in memory as a string. This makes it easy to write
+// the sitemap to an http.ResponseWriter, a file or a gzip.Writer.
+//
+// Unlike GenerateSitemap no empty sitemap is written when an error occurs, and part of the
+// sitemap might already have been written to w by the time an error is returned.
+func (mapper *SiteMapper) WriteSitemap(w io.Writer, baseDomain string, filterPattern string) error {
+	urls, err := mapper.sitemapURLs(baseDomain, filterPattern)
 	if err != nil {
-		return mapper.EmptySitemapXML(baseDomain), fmt.Errorf("invalid filter pattern provided: %w", err)
+		return err
+	}
+
+	return mapper.writeURLSet(w, urls)
+}
+
+// GenerateSitemapGzip generates the same sitemap as GenerateSitemap but compressed with gzip,
+// ready to be served as "sitemap.xml.gz".
+func (mapper *SiteMapper) GenerateSitemapGzip(baseDomain string, filterPattern string) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	if err := mapper.WriteSitemapGzip(&buffer, baseDomain, filterPattern); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// WriteSitemapGzip streams the same sitemap as WriteSitemap into w, compressed with gzip.
+func (mapper *SiteMapper) WriteSitemapGzip(w io.Writer, baseDomain string, filterPattern string) error {
+	gzipWriter := gzip.NewWriter(w)
+
+	if err := mapper.WriteSitemap(gzipWriter, baseDomain, filterPattern); err != nil {
+		gzipWriter.Close()
+		return err
+	}
+
+	if err := gzipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to compress xml: %w", err)
 	}
 
-	for _, link := range links {
-		if filter.MatchString(link.link) {
+	return nil
+}
+
+// GenerateSitemapJSON generates the same URLs as GenerateSitemap but as a JSON array of objects
+// with "loc", "lastmod", "changefreq" and "priority" keys. This is easier to diff between crawls
+// or to load into other tools than XML. The baseDomain and filterPattern work the same way as
+// they do for GenerateSitemap.
+func (mapper *SiteMapper) GenerateSitemapJSON(baseDomain string, filterPattern string) ([]byte, error) {
+	urls, err := mapper.sitemapURLs(baseDomain, filterPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonURLs := make([]jsonSitemapURL, 0, len(urls))
+	for _, url := range urls {
+		jsonURLs = append(jsonURLs, jsonSitemapURL{
+			Location:     url.Location,
+			LastModified: url.LastModified,
+			ChangeFreq:   url.ChangeFreq,
+			Priority:     url.Priority,
+		})
+	}
+
+	jsonBytes, err := json.MarshalIndent(jsonURLs, "", "	")
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate json: %w", err)
+	}
+
+	return jsonBytes, nil
+}
+
+// PreviewURLs returns the locations that GenerateSitemap would put in the sitemap, without
+// generating any XML. The baseDomain and filterPattern work the same way as they do for
+// GenerateSitemap, so this is handy for checking which URLs are or aren't in the sitemap.
+func (mapper *SiteMapper) PreviewURLs(baseDomain string, filterPattern string) ([]string, error) {
+	urls, err := mapper.sitemapURLs(baseDomain, filterPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	locations := make([]string, 0, len(urls))
+	for _, url := range urls {
+		locations = append(locations, url.Location)
+	}
+
+	return locations, nil
+}
+
+// GenerateSitemapIndex generates a sitemap index along with the sitemap files it references. The
+// URLs are split into files named "sitemap-1.xml", "sitemap-2.xml" and so on, each containing at
+// most the number of URLs set with SetMaxURLsPerSitemap. The index references every file relative
+// to baseDomain, so the files should be served from the root of the site.
+//
+// The files are returned as a map from file name to file content. The baseDomain and filterPattern
+// work the same way as they do for GenerateSitemap.
+func (mapper *SiteMapper) GenerateSitemapIndex(baseDomain string, filterPattern string) (string, map[string]string, error) {
+	filter, err := mapper.patternFilter(filterPattern)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// The times are kept around to find the most recent lastmod of each file, since the
+	// formatted dates don't have to sort in order.
+	urls := mapper.datedSitemapURLs(baseDomain, filter)
+
+	maxURLs := mapper.maxURLsPerSitemap
+	if maxURLs <= 0 {
+		maxURLs = maxSitemapURLs
+	}
+
+	sitemapIndex := sitemapIndex{
+		Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9",
+	}
+
+	files := make(map[string]string)
+
+	// A sitemap index has to reference at least one sitemap, even if it's empty.
+	chunks := slices.Collect(slices.Chunk(urls, maxURLs))
+	if len(chunks) == 0 {
+		chunks = [][]datedSitemapURL{{}}
+	}
+
+	for i, chunk := range chunks {
+		fileName := fmt.Sprintf("sitemap-%d.xml", i+1)
+
+		// The file was last modified when the most recent of its URLs was.
+		chunkURLs := make([]sitemapURL, len(chunk))
+		var lastModified time.Time
+		for j, url := range chunk {
+			chunkURLs[j] = url.sitemapURL
+			if j == 0 || url.lastMod.After(lastModified) {
+				lastModified = url.lastMod
+			}
+		}
+
+		sitemap, err := mapper.marshalURLSet(chunkURLs)
+		if err != nil {
+			return "", nil, err
+		}
+
+		files[fileName] = sitemap
+
+		entry := sitemapIndexEntry{Location: baseDomain + "/" + fileName}
+		if len(chunk) > 0 {
+			entry.LastModified = mapper.formatLastMod(lastModified)
+		}
+
+		sitemapIndex.Sitemaps = append(sitemapIndex.Sitemaps, entry)
+	}
+
+	xmlBytes, err := xml.MarshalIndent(sitemapIndex, "", mapper.sitemapIndent)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate xml: %w", err)
+	}
+
+	return xmlDeclaration + string(xmlBytes), files, nil
+}
+
+// sitemapURLs collects the links that should be included in the sitemap, replacing the crawled
+// domain with baseDomain. Depending on the filter mode the links that match the filter pattern
+// are either skipped or the only ones that are collected.
+func (mapper *SiteMapper) sitemapURLs(baseDomain string, filterPattern string) ([]sitemapURL, error) {
+	filter, err := mapper.patternFilter(filterPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapper.filteredSitemapURLs(baseDomain, filter), nil
+}
+
+// patternFilter compiles the filter pattern into a filter that either excludes or only includes
+// the links that match it, depending on the filter mode.
+func (mapper *SiteMapper) patternFilter(filterPattern string) (sitemapFilter, error) {
+	pattern, err := regexp.Compile(filterPattern)
+	if err != nil {
+		return sitemapFilter{}, fmt.Errorf("invalid filter pattern provided: %w", err)
+	}
+
+	if mapper.filterMode == FilterInclude {
+		return sitemapFilter{include: []*regexp.Regexp{pattern}}, nil
+	}
+
+	return sitemapFilter{exclude: []*regexp.Regexp{pattern}}, nil
+}
+
+// filteredSitemapURLs collects the links that pass the filter, replacing the crawled domain
+// with baseDomain.
+func (mapper *SiteMapper) filteredSitemapURLs(baseDomain string, filter sitemapFilter) []sitemapURL {
+	dated := mapper.datedSitemapURLs(baseDomain, filter)
+
+	urls := make([]sitemapURL, len(dated))
+	for i, url := range dated {
+		urls[i] = url.sitemapURL
+	}
+
+	return urls
+}
+
+// datedSitemapURL is a sitemap URL along with the time its lastmod was formatted from.
+type datedSitemapURL struct {
+	sitemapURL
+	lastMod time.Time
+}
+
+// datedSitemapURLs collects the links that pass the filter the same way filteredSitemapURLs does,
+// but keeps the time of each lastmod around.
+//
+// Different links can end up with the same location once the domain has been replaced. Only
+// the most recently modified of those links is kept since the sitemap protocol doesn't allow
+// the same location more than once.
+func (mapper *SiteMapper) datedSitemapURLs(baseDomain string, filter sitemapFilter) []datedSitemapURL {
+	links := mapper.orderedLinks()
+	candidates := mapper.sitemapCandidates(links, baseDomain, filter)
+
+	var urls []datedSitemapURL
+
+	// seen maps each location to the index of its URL.
+	seen := make(map[string]int, len(links))
+
+	for i, link := range links {
+		if !candidates[i].ok {
 			continue
 		}
 
-		url := sitemapURL{
-			Location:     replaceDomain(link.link, mapper.domain, baseDomain),
-			LastModified: link.lastChanged.Format("2006-01-02"),
+		url := datedSitemapURL{sitemapURL: candidates[i].url, lastMod: link.lastMod()}
+
+		if previous, has := seen[url.Location]; has {
+			if url.lastMod.After(urls[previous].lastMod) {
+				urls[previous] = url
+			}
+
+			continue
 		}
 
+		seen[url.Location] = len(urls)
 		urls = append(urls, url)
 	}
 
-	urlSet.URLS = urls
+	// The home page goes first since that's where the crawl would have found it.
+	if root, ok := mapper.spider.normalizeURL(mapper.domain); mapper.alwaysIncludeRoot && ok && filter.allows(root) {
+		location := replaceDomain(root, mapper.domain, baseDomain)
+		if _, has := seen[location]; !has {
+			now := time.Now()
+			urls = slices.Insert(urls, 0, datedSitemapURL{
+				sitemapURL: sitemapURL{
+					Location:     location,
+					LastModified: mapper.formatLastMod(now),
+					ChangeFreq:   mapper.changeFreq(root),
+					Priority:     mapper.priority(root),
+				},
+				lastMod: now,
+			})
+		}
+	}
 
-	xmlBytes, err := xml.MarshalIndent(urlSet, "", "	")
-	if err != nil {
-		return mapper.EmptySitemapXML(baseDomain), fmt.Errorf("failed to generate xml: %w", err)
+	// Replacing the domain can change the alphabetical order of the links.
+	if mapper.sitemapOrdering == SitemapOrderAlphabetical {
+		slices.SortFunc(urls, func(a, b datedSitemapURL) int {
+			return strings.Compare(a.Location, b.Location)
+		})
+	}
+
+	return urls
+}
+
+// sitemapCandidate is the sitemap URL of a link, if the link belongs in the sitemap.
+type sitemapCandidate struct {
+	url sitemapURL
+	ok  bool
+}
+
+// sitemapCandidates filters the links and turns the ones that belong in the sitemap into sitemap
+// URLs. The candidate at each index belongs to the link at the same index. Large sets of links
+// are split into chunks that are handled by separate goroutines.
+func (mapper *SiteMapper) sitemapCandidates(links []crawlerURL, baseDomain string, filter sitemapFilter) []sitemapCandidate {
+	candidates := make([]sitemapCandidate, len(links))
+
+	transform := func(start int, end int) {
+		for i := start; i < end; i++ {
+			link := links[i]
+
+			// Pages that asked to not be indexed don't belong in the sitemap.
+			if !link.indexable() || !filter.allows(link.link) {
+				continue
+			}
+
+			candidates[i] = sitemapCandidate{
+				url: sitemapURL{
+					Location:     replaceDomain(link.link, mapper.domain, baseDomain),
+					LastModified: mapper.formatLastMod(link.lastMod()),
+					ChangeFreq:   mapper.changeFreq(link.link),
+					Priority:     mapper.priority(link.link),
+				},
+				ok: true,
+			}
+		}
+	}
+
+	workers := runtime.GOMAXPROCS(0)
+	if len(links) < parallelFilterThreshold || workers == 1 {
+		transform(0, len(links))
+		return candidates
+	}
+
+	// Every goroutine writes to its own part of candidates so no locking is needed.
+	chunkSize := (len(links) + workers - 1) / workers
+
+	var wg sync.WaitGroup
+	for start := 0; start < len(links); start += chunkSize {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			transform(start, min(start+chunkSize, len(links)))
+		}()
+	}
+
+	wg.Wait()
+
+	return candidates
+}
+
+// orderedLinks returns the known links in the order set with SetSitemapOrdering. Links with
+// the same crawl order, like the ones kept from an earlier crawl, are sorted alphabetically.
+func (mapper *SiteMapper) orderedLinks() []crawlerURL {
+	links := mapper.spider.getLinks()
+
+	slices.SortFunc(links, func(a, b crawlerURL) int {
+		if mapper.sitemapOrdering == SitemapOrderCrawl && a.crawlOrder != b.crawlOrder {
+			return a.crawlOrder - b.crawlOrder
+		}
+
+		return strings.Compare(a.link, b.link)
+	})
+
+	return links
+}
+
+// newSitemapFilter compiles the exclude and include patterns into a sitemapFilter.
+func newSitemapFilter(exclude []string, include []string) (sitemapFilter, error) {
+	var filter sitemapFilter
+
+	for _, pattern := range include {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return sitemapFilter{}, fmt.Errorf("invalid include pattern provided: %w", err)
+		}
+
+		filter.include = append(filter.include, compiled)
+	}
+
+	for _, pattern := range exclude {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return sitemapFilter{}, fmt.Errorf("invalid exclude pattern provided: %w", err)
+		}
+
+		filter.exclude = append(filter.exclude, compiled)
+	}
+
+	return filter, nil
+}
+
+// allows checks whether the link should be included in the sitemap.
+func (filter sitemapFilter) allows(link string) bool {
+	if len(filter.include) > 0 && !slices.ContainsFunc(filter.include, func(pattern *regexp.Regexp) bool {
+		return pattern.MatchString(link)
+	}) {
+		return false
+	}
+
+	return !slices.ContainsFunc(filter.exclude, func(pattern *regexp.Regexp) bool {
+		return pattern.MatchString(link)
+	})
+}
+
+// marshalURLSet generates the sitemap XML for the given URLs.
+func (mapper *SiteMapper) marshalURLSet(urls []sitemapURL) (string, error) {
+	var builder strings.Builder
+
+	if err := mapper.writeURLSet(&builder, urls); err != nil {
+		return "", err
 	}
 
-	xmlHeader := []byte(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
-	return string(append(xmlHeader, xmlBytes...)), nil
+	return builder.String(), nil
 }
 
+// writeURLSet encodes the sitemap XML for the given URLs straight into w.
+func (mapper *SiteMapper) writeURLSet(w io.Writer, urls []sitemapURL) error {
+	urlSet := sitemapURLSet{
+		Xmlns:        "http://www.sitemaps.org/schemas/sitemap/0.9",
+		XmlnsXsi:     "http://www.w3.org/2001/XMLSchema-instance",
+		XsiSchemaLoc: "http://www.sitemaps.org/schemas/sitemap/0.9 http://www.sitemaps.org/schemas/sitemap/0.9/sitemap.xsd",
+		URLS:         urls,
+	}
+
+	if _, err := io.WriteString(w, xmlDeclaration); err != nil {
+		return fmt.Errorf("failed to write xml: %w", err)
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", mapper.sitemapIndent)
+
+	if err := encoder.Encode(urlSet); err != nil {
+		return fmt.Errorf("failed to generate xml: %w", err)
+	}
+
+	return nil
+}
+
+// EmptySitemapXML generates a valid sitemap without any URLs. It can be served as a fallback
+// before the first crawl has finished without advertising pages that were never crawled.
+// baseDomain is no longer used and is only kept for backwards compatibility.
 func (mapper *SiteMapper) EmptySitemapXML(baseDomain string) string {
-	emptySiteMap := fmt.Sprintf(`<?xml version='1.0' encoding='UTF-8'?>
-		<urlset xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.sitemaps.org/schemas/sitemap/0.9 http://www.sitemaps.org/schemas/sitemap/0.9/sitemap.xsd" xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
-			<url>
-				<loc>%s/</loc>
-				<lastmod>%s</lastmod>
-			</url>
-		</urlset>
-		`, baseDomain, time.Now().Format("2006-01-02"))
+	// Encoding an empty URL set can't fail.
+	emptySiteMap, _ := mapper.marshalURLSet(nil)
 
 	return emptySiteMap
 }
 
+// formatLastMod formats the time for the <lastmod> of a URL using the layout set with
+// SetLastModFormat.
+func (mapper *SiteMapper) formatLastMod(t time.Time) string {
+	layout := mapper.lastModFormat
+	if layout == "" {
+		layout = defaultLastModFormat
+	}
+
+	return t.Format(layout)
+}
+
+// changeFreq returns the change frequency of the first rule that matches the link. An empty
+// string is returned if no rule matches.
+func (mapper *SiteMapper) changeFreq(link string) string {
+	for _, rule := range mapper.changeFreqs {
+		if rule.pattern.MatchString(link) {
+			return rule.freq
+		}
+	}
+
+	return ""
+}
+
+// priority returns the priority of the first rule that matches the link, formatted for the
+// sitemap. An empty string is returned if no rule matches.
+func (mapper *SiteMapper) priority(link string) string {
+	for _, rule := range mapper.priorities {
+		if rule.pattern.MatchString(link) {
+			priority := strconv.FormatFloat(rule.priority, 'f', -1, 64)
+			if !strings.Contains(priority, ".") {
+				priority += ".0"
+			}
+
+			return priority
+		}
+	}
+
+	return ""
+}
+
 func replaceDomain(link, oldDomain, newDomain string) string {
 	if strings.HasPrefix(link, oldDomain) {
 		return strings.Replace(link, oldDomain, newDomain, 1)
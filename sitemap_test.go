@@ -0,0 +1,627 @@
+package sitemapper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestSiteMapper creates a SiteMapper for "http://example.com" whose crawler already
+// knows about the given links, without starting the background crawl.
+func newTestSiteMapper(links ...crawlerURL) *SiteMapper {
+	spider := newCrawler("http://example.com", nil, func(string) {}, func(error) {})
+	for _, link := range links {
+		spider.links[link.link] = link
+	}
+
+	return &SiteMapper{
+		spider: spider,
+		done:   make(chan struct{}),
+		domain: "http://example.com",
+
+		sitemapIndent: defaultSitemapIndent,
+	}
+}
+
+func TestGenerateSitemapChangeFreq(t *testing.T) {
+	lastChanged := time.Date(2025, time.January, 2, 0, 0, 0, 0, time.UTC)
+
+	mapper := newTestSiteMapper(
+		crawlerURL{link: "http://example.com/blog/post", lastChanged: lastChanged},
+		crawlerURL{link: "http://example.com/about", lastChanged: lastChanged},
+	)
+
+	options := DefaultOptions()
+
+	if err := options.SetChangeFreq(`/blog/`, "weekly"); err != nil {
+		t.Fatal(err)
+	}
+
+	mapper.changeFreqs = options.changeFreqs
+
+	sitemap, err := mapper.GenerateSitemap("https://example.com", "^$")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<loc>https://example.com/blog/post</loc>\n\t\t<lastmod>2025-01-02</lastmod>\n\t\t<changefreq>weekly</changefreq>"
+	if !strings.Contains(sitemap, expected) {
+		t.Errorf("Expected the blog post to have a change frequency, got:\n%s", sitemap)
+	}
+
+	expected = "<loc>https://example.com/about</loc>\n\t\t<lastmod>2025-01-02</lastmod>\n\t</url>"
+	if !strings.Contains(sitemap, expected) {
+		t.Errorf("Expected the about page to not have a change frequency, got:\n%s", sitemap)
+	}
+}
+
+func TestGenerateSitemapPriority(t *testing.T) {
+	mapper := newTestSiteMapper(
+		crawlerURL{link: "http://example.com"},
+		crawlerURL{link: "http://example.com/blog/post"},
+		crawlerURL{link: "http://example.com/about"},
+	)
+
+	options := DefaultOptions()
+
+	if err := options.SetPriority(`^http://example\.com$`, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := options.SetPriority(`/blog/`, 0.75); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := options.SetPriority(`.*`, 0.5); err != nil {
+		t.Fatal(err)
+	}
+
+	mapper.priorities = options.priorities
+
+	sitemap, err := mapper.GenerateSitemap("https://example.com", "^$")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		location string
+		priority string
+	}{
+		{"https://example.com", "1.0"},
+		{"https://example.com/blog/post", "0.75"},
+		{"https://example.com/about", "0.5"},
+	}
+
+	for _, test := range tests {
+		expected := "<loc>" + test.location + "</loc>\n\t\t<lastmod>0001-01-01</lastmod>\n\t\t<priority>" + test.priority + "</priority>"
+		if !strings.Contains(sitemap, expected) {
+			t.Errorf("Expected '%s' to have priority %s, got:\n%s", test.location, test.priority, sitemap)
+		}
+	}
+}
+
+func TestGenerateSitemapIndex(t *testing.T) {
+	mapper := newTestSiteMapper(
+		crawlerURL{link: "http://example.com", lastChanged: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		crawlerURL{link: "http://example.com/page1", lastChanged: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		crawlerURL{link: "http://example.com/page2", lastChanged: time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)},
+	)
+	mapper.maxURLsPerSitemap = 2
+
+	index, files, err := mapper.GenerateSitemapIndex("https://example.com", "^$")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 sitemap files, got %d", len(files))
+	}
+
+	total := 0
+	for _, fileName := range []string{"sitemap-1.xml", "sitemap-2.xml"} {
+		sitemap, has := files[fileName]
+		if !has {
+			t.Fatalf("Expected to find '%s'", fileName)
+		}
+
+		urls, err := extractURLsFromSitemap(sitemap)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(urls) > 2 {
+			t.Errorf("Expected '%s' to contain at most 2 URLs, got %d", fileName, len(urls))
+		}
+
+		total += len(urls)
+
+		if !strings.Contains(index, "<loc>https://example.com/"+fileName+"</loc>") {
+			t.Errorf("Expected the index to reference '%s', got:\n%s", fileName, index)
+		}
+	}
+
+	if total != 3 {
+		t.Errorf("Expected 3 URLs across all the files, got %d", total)
+	}
+
+	if !strings.HasPrefix(index, `<?xml version="1.0" encoding="UTF-8"?>`+"\n<sitemapindex") {
+		t.Errorf("Expected a sitemap index, got:\n%s", index)
+	}
+}
+
+func TestGenerateSitemapIndexEmpty(t *testing.T) {
+	mapper := newTestSiteMapper()
+
+	index, files, err := mapper.GenerateSitemapIndex("https://example.com", "^$")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 {
+		t.Errorf("Expected 1 sitemap file, got %d", len(files))
+	}
+
+	if !strings.Contains(index, "<loc>https://example.com/sitemap-1.xml</loc>") {
+		t.Errorf("Expected the index to reference 'sitemap-1.xml', got:\n%s", index)
+	}
+}
+
+func TestWriteSitemap(t *testing.T) {
+	mapper := newTestSiteMapper(
+		crawlerURL{link: "http://example.com"},
+		crawlerURL{link: "http://example.com/page1"},
+		crawlerURL{link: "http://example.com/htmx"},
+	)
+
+	var builder strings.Builder
+
+	if err := mapper.WriteSitemap(&builder, "https://example.com", "/htmx"); err != nil {
+		t.Fatal(err)
+	}
+
+	urls, err := extractURLsFromSitemap(builder.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slices.Sort(urls)
+
+	expected := []string{"https://example.com", "https://example.com/page1"}
+	if !slices.Equal(urls, expected) {
+		t.Errorf("Expected to find %v, got %v", expected, urls)
+	}
+
+	if err := mapper.WriteSitemap(&builder, "https://example.com", "("); err == nil {
+		t.Error("Expected an error for the invalid filter pattern")
+	}
+}
+
+func TestGenerateSitemapGzip(t *testing.T) {
+	mapper := newTestSiteMapper(crawlerURL{link: "http://example.com/page1"})
+
+	compressed, err := mapper.GenerateSitemapGzip("https://example.com", "^$")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sitemap, err := mapper.GenerateSitemap("https://example.com", "^$")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(decompressed) != sitemap {
+		t.Errorf("Expected the decompressed sitemap to match GenerateSitemap, got:\n%s\nwant:\n%s", decompressed, sitemap)
+	}
+}
+
+func TestGenerateSitemapNoindex(t *testing.T) {
+	mapper := newTestSiteMapper(
+		crawlerURL{link: "http://example.com/about"},
+		crawlerURL{link: "http://example.com/thank-you", noindex: true},
+	)
+
+	sitemap, err := mapper.GenerateSitemap("https://example.com", "^$")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(sitemap, "<loc>https://example.com/about</loc>") {
+		t.Errorf("Expected sitemap to contain '/about', got:\n%s", sitemap)
+	}
+
+	if strings.Contains(sitemap, "thank-you") {
+		t.Errorf("Expected sitemap to not contain the noindex page, got:\n%s", sitemap)
+	}
+}
+
+func TestGenerateSitemapJSON(t *testing.T) {
+	lastChanged := time.Date(2025, time.January, 2, 0, 0, 0, 0, time.UTC)
+
+	mapper := newTestSiteMapper(
+		crawlerURL{link: "http://example.com/about", lastChanged: lastChanged},
+		crawlerURL{link: "http://example.com/htmx/partial", lastChanged: lastChanged},
+	)
+
+	jsonBytes, err := mapper.GenerateSitemapJSON("https://example.com", "/htmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var urls []map[string]string
+	if err := json.Unmarshal(jsonBytes, &urls); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []map[string]string{{"loc": "https://example.com/about", "lastmod": "2025-01-02"}}
+	if !reflect.DeepEqual(urls, expected) {
+		t.Errorf("Expected %v, got %v", expected, urls)
+	}
+
+	// An empty sitemap should still be a JSON array.
+	jsonBytes, err = newTestSiteMapper().GenerateSitemapJSON("https://example.com", "^$")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(jsonBytes) != "[]" {
+		t.Errorf("Expected an empty JSON array, got %s", jsonBytes)
+	}
+
+	if _, err := mapper.GenerateSitemapJSON("https://example.com", "["); err == nil {
+		t.Error("Expected an error for an invalid filter pattern")
+	}
+}
+
+func TestGenerateSitemapFiltered(t *testing.T) {
+	mapper := newTestSiteMapper(
+		crawlerURL{link: "http://example.com/about"},
+		crawlerURL{link: "http://example.com/blog/post"},
+		crawlerURL{link: "http://example.com/blog/draft"},
+		crawlerURL{link: "http://example.com/htmx/partial"},
+	)
+
+	tests := []struct {
+		name     string
+		exclude  []string
+		include  []string
+		expected []string
+	}{
+		{"No patterns", nil, nil, []string{"/about", "/blog/post", "/blog/draft", "/htmx/partial"}},
+		{"Exclude only", []string{"/htmx", "draft"}, nil, []string{"/about", "/blog/post"}},
+		{"Include only", nil, []string{"/blog/", "/about$"}, []string{"/about", "/blog/post", "/blog/draft"}},
+		{"Include then exclude", []string{"draft"}, []string{"/blog/"}, []string{"/blog/post"}},
+	}
+
+	for _, test := range tests {
+		sitemap, err := mapper.GenerateSitemapFiltered("https://example.com", test.exclude, test.include)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		urls, err := extractURLsFromSitemap(sitemap)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		expected := make([]string, 0, len(test.expected))
+		for _, path := range test.expected {
+			expected = append(expected, "https://example.com"+path)
+		}
+
+		slices.Sort(urls)
+		slices.Sort(expected)
+
+		if !slices.Equal(urls, expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, expected, urls)
+		}
+	}
+
+	if _, err := mapper.GenerateSitemapFiltered("https://example.com", []string{"["}, nil); err == nil {
+		t.Error("Expected an error for an invalid exclude pattern")
+	}
+
+	if _, err := mapper.GenerateSitemapFiltered("https://example.com", nil, []string{"["}); err == nil {
+		t.Error("Expected an error for an invalid include pattern")
+	}
+}
+
+func TestGenerateSitemapFilterMode(t *testing.T) {
+	mapper := newTestSiteMapper(
+		crawlerURL{link: "http://example.com/about"},
+		crawlerURL{link: "http://example.com/blog/post"},
+	)
+
+	tests := []struct {
+		mode     FilterMode
+		expected []string
+	}{
+		{FilterExclude, []string{"https://example.com/about"}},
+		{FilterInclude, []string{"https://example.com/blog/post"}},
+	}
+
+	for _, test := range tests {
+		mapper.filterMode = test.mode
+
+		sitemap, err := mapper.GenerateSitemap("https://example.com", "/blog/")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		urls, err := extractURLsFromSitemap(sitemap)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !slices.Equal(urls, test.expected) {
+			t.Errorf("Filter mode %d: expected %v, got %v", test.mode, test.expected, urls)
+		}
+	}
+}
+
+func TestGenerateSitemapLastModFormat(t *testing.T) {
+	lastModified := time.Date(2025, time.January, 2, 15, 4, 5, 0, time.UTC)
+
+	mapper := newTestSiteMapper(crawlerURL{link: "http://example.com/about", lastModified: lastModified})
+	mapper.lastModFormat = time.RFC3339
+
+	sitemap, err := mapper.GenerateSitemap("https://example.com", "^$")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(sitemap, "<lastmod>2025-01-02T15:04:05Z</lastmod>") {
+		t.Errorf("Expected the lastmod to be a full timestamp, got:\n%s", sitemap)
+	}
+}
+
+func TestGenerateSitemapIndent(t *testing.T) {
+	mapper := newTestSiteMapper(crawlerURL{link: "http://example.com/about", lastModified: time.Now()})
+
+	mapper.sitemapIndent = "  "
+	sitemap, err := mapper.GenerateSitemap("https://example.com", "^$")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(sitemap, "\n  <url>\n    <loc>") {
+		t.Errorf("Expected the sitemap to be indented with two spaces, got:\n%s", sitemap)
+	}
+
+	mapper.sitemapIndent = ""
+	sitemap, err = mapper.GenerateSitemap("https://example.com", "^$")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(sitemap, "><url><loc>https://example.com/about</loc><lastmod>") {
+		t.Errorf("Expected a compact sitemap, got:\n%s", sitemap)
+	}
+
+	if empty := mapper.EmptySitemapXML("https://example.com"); !strings.HasSuffix(empty, "sitemap.xsd\"></urlset>") {
+		t.Errorf("Expected a compact empty sitemap, got:\n%s", empty)
+	}
+}
+
+func TestEmptySitemapXMLMatchesGenerateSitemap(t *testing.T) {
+	mapper := newTestSiteMapper(crawlerURL{link: "http://example.com/about", lastModified: time.Now()})
+
+	sitemap, err := mapper.GenerateSitemap("https://example.com", "^$")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	empty := mapper.EmptySitemapXML("https://example.com")
+
+	header, _, _ := strings.Cut(sitemap, "<url>")
+	emptyHeader, _, _ := strings.Cut(empty, "</urlset>")
+	if strings.TrimSpace(header) != emptyHeader {
+		t.Errorf("Expected the empty sitemap to start with %q, got %q", header, emptyHeader)
+	}
+
+	if strings.Contains(empty, "<url>") {
+		t.Errorf("Expected the empty sitemap to not contain any URLs, got:\n%s", empty)
+	}
+
+	if !strings.HasPrefix(empty, xmlDeclaration+"<urlset") {
+		t.Errorf("Expected the empty sitemap to start with the XML declaration, got:\n%s", empty)
+	}
+}
+
+func TestGenerateSitemapAlwaysIncludeRoot(t *testing.T) {
+	mapper := newTestSiteMapper(crawlerURL{link: "http://example.com/about", lastChanged: time.Now()})
+
+	for _, include := range []bool{false, true} {
+		mapper.alwaysIncludeRoot = include
+
+		sitemap, err := mapper.GenerateSitemap("https://example.com", "^$")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		urls, err := extractURLsFromSitemap(sitemap)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if slices.Contains(urls, "https://example.com") != include {
+			t.Errorf("Expected the root to be in the sitemap to be %t, got %v", include, urls)
+		}
+	}
+
+	// The root isn't added twice when the crawl found it.
+	mapper = newTestSiteMapper(crawlerURL{link: "http://example.com", lastChanged: time.Now()})
+	mapper.alwaysIncludeRoot = true
+
+	urls, err := mapper.PreviewURLs("https://example.com", "^$")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(urls) != 1 {
+		t.Errorf("Expected only the root, got %v", urls)
+	}
+}
+
+func TestGenerateSitemapManyLinks(t *testing.T) {
+	links := make([]crawlerURL, 0, parallelFilterThreshold*2)
+	for i := range parallelFilterThreshold * 2 {
+		links = append(links, crawlerURL{link: fmt.Sprintf("http://example.com/page%d", i), lastChanged: time.Now()})
+	}
+
+	mapper := newTestSiteMapper(links...)
+
+	urls, err := mapper.PreviewURLs("https://example.com", "/page1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every page that starts with "page1" is filtered out.
+	expected := make([]string, 0, len(links))
+	for i := range len(links) {
+		if location := fmt.Sprintf("https://example.com/page%d", i); !strings.HasPrefix(location, "https://example.com/page1") {
+			expected = append(expected, location)
+		}
+	}
+	slices.Sort(expected)
+
+	if !slices.Equal(urls, expected) {
+		t.Errorf("Expected %d sorted URLs, got %d", len(expected), len(urls))
+	}
+}
+
+func BenchmarkGenerateSitemap(b *testing.B) {
+	links := make([]crawlerURL, 0, 100000)
+	for i := range 100000 {
+		links = append(links, crawlerURL{link: fmt.Sprintf("http://example.com/page%d", i), lastChanged: time.Now()})
+	}
+
+	mapper := newTestSiteMapper(links...)
+
+	b.ResetTimer()
+	for range b.N {
+		if _, err := mapper.GenerateSitemap("https://example.com", "/htmx"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestGenerateSitemapDeduplicates(t *testing.T) {
+	older := time.Date(2025, time.January, 2, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2025, time.March, 4, 0, 0, 0, 0, time.UTC)
+
+	// Both links end up as "https://example.com/about" once the domain has been replaced.
+	mapper := newTestSiteMapper(
+		crawlerURL{link: "http://example.com/about", lastChanged: older},
+		crawlerURL{link: "https://example.com/about", lastChanged: newer},
+	)
+
+	sitemap, err := mapper.GenerateSitemap("https://example.com", "^$")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count := strings.Count(sitemap, "<loc>https://example.com/about</loc>"); count != 1 {
+		t.Errorf("Expected the location to appear once, got %d times:\n%s", count, sitemap)
+	}
+
+	if !strings.Contains(sitemap, "<lastmod>2025-03-04</lastmod>") {
+		t.Errorf("Expected the most recently modified link to be kept, got:\n%s", sitemap)
+	}
+}
+
+func TestGenerateSitemapOrdering(t *testing.T) {
+	mapper := newTestSiteMapper(
+		crawlerURL{link: "http://example.com", crawlOrder: 0},
+		crawlerURL{link: "http://example.com/zebra", crawlOrder: 1},
+		crawlerURL{link: "http://example.com/about", crawlOrder: 2},
+		crawlerURL{link: "http://example.com/blog", crawlOrder: 3},
+	)
+
+	tests := []struct {
+		ordering SitemapOrdering
+		expected []string
+	}{
+		{SitemapOrderAlphabetical, []string{"https://example.com", "https://example.com/about", "https://example.com/blog", "https://example.com/zebra"}},
+		{SitemapOrderCrawl, []string{"https://example.com", "https://example.com/zebra", "https://example.com/about", "https://example.com/blog"}},
+	}
+
+	for _, test := range tests {
+		mapper.sitemapOrdering = test.ordering
+
+		// The order shouldn't depend on the order of the links map.
+		for range 5 {
+			sitemap, err := mapper.GenerateSitemap("https://example.com", "^$")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			urls, err := extractURLsFromSitemap(sitemap)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(urls, test.expected) {
+				t.Fatalf("Ordering %d: expected %v, got %v", test.ordering, test.expected, urls)
+			}
+		}
+	}
+}
+
+func TestPreviewURLs(t *testing.T) {
+	mapper := newTestSiteMapper(
+		crawlerURL{link: "http://example.com/about"},
+		crawlerURL{link: "http://example.com/htmx/partial"},
+		crawlerURL{link: "http://example.com/blog"},
+		crawlerURL{link: "http://example.com/thank-you", noindex: true},
+	)
+
+	urls, err := mapper.PreviewURLs("https://example.com", "/htmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"https://example.com/about", "https://example.com/blog"}
+	if !slices.Equal(urls, expected) {
+		t.Errorf("Expected %v, got %v", expected, urls)
+	}
+
+	if _, err := mapper.PreviewURLs("https://example.com", "["); err == nil {
+		t.Error("Expected an error for an invalid filter pattern")
+	}
+}
+
+func TestGenerateSitemapIndexLastModFormat(t *testing.T) {
+	// With RFC 1123 "Wed, 01 Jan 2025" sorts after "Sat, 01 Mar 2025" even though it's older.
+	mapper := newTestSiteMapper(
+		crawlerURL{link: "http://example.com", lastChanged: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		crawlerURL{link: "http://example.com/page1", lastChanged: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)},
+	)
+	mapper.lastModFormat = time.RFC1123
+
+	index, _, err := mapper.GenerateSitemapIndex("https://example.com", "^$")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<lastmod>Sat, 01 Mar 2025 00:00:00 UTC</lastmod>"
+	if !strings.Contains(index, expected) {
+		t.Errorf("Expected the index to contain '%s', got:\n%s", expected, index)
+	}
+}
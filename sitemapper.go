@@ -1,6 +1,44 @@
 package sitemapper
 
-import "time"
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Version is the current version of SiteMapper.
+const Version = "1.0.0"
+
+// DefaultUserAgent is the User-Agent header the crawler sends when no other user-agent has been set.
+const DefaultUserAgent = "sitemapper/" + Version
+
+// ErrCrawlInProgress is returned by RecrawlSite when a recrawl can't be queued because the
+// site is being crawled.
+var ErrCrawlInProgress = errors.New("sitemapper: crawl in progress")
+
+// ErrStopped is returned by RecrawlSite once the SiteMapper has been stopped.
+var ErrStopped = errors.New("sitemapper: stopped")
+
+// ErrMaxCrawlDuration is returned by a crawl that stopped because it took longer than the
+// duration set with SetMaxCrawlDuration.
+var ErrMaxCrawlDuration = errors.New("sitemapper: max crawl duration exceeded")
+
+// ErrRequestBudgetExhausted is returned by a crawl that stopped, or couldn't start, because the
+// requests set with SetRequestBudget have all been used.
+var ErrRequestBudgetExhausted = errors.New("sitemapper: request budget exhausted")
+
+// ErrBelowFallbackThreshold is returned by a crawl that found fewer of the previously known
+// pages than the threshold set with SetFallbackThreshold. The previous links are kept in that case.
+var ErrBelowFallbackThreshold = errors.New("sitemapper: crawl found too few of the known pages")
+
+// ErrUnreachable is wrapped by the error the preflight check returns when the starting URL
+// couldn't be reached. See SetPreflightCheck.
+var ErrUnreachable = errors.New("sitemapper: domain unreachable")
 
 // SiteMapper is responsible for managing the crawling and sitemap generation for a specified domain.
 // It schedules periodic crawls and allows manual recrawling.
@@ -13,14 +51,114 @@ type SiteMapper struct {
 	// recrawlSignal is a channel used to trigger manual recrawling.
 	recrawlSignal chan bool
 
+	// done is closed by Stop to terminate the background goroutine.
+	done chan struct{}
+
+	// stopOnce ensures that done is only closed once.
+	stopOnce sync.Once
+
 	// domain is the domain name of the site being crawled.
 	domain string
+
+	// startingURL is the URL where every crawl begins.
+	startingURL string
+
+	// callbackFunc is called after each crawl has finished.
+	callbackFunc func(*SiteMapper)
+
+	// preCrawlFunc is called right before each crawl starts.
+	preCrawlFunc func(*SiteMapper)
+
+	// checkpointFunc is called every time the crawler has taken a checkpoint.
+	checkpointFunc func(*SiteMapper)
+
+	// durationBeforeFirstCrawl is the delay before the background goroutine performs the first crawl.
+	durationBeforeFirstCrawl time.Duration
+
+	// blockUntilFirstCrawl determines whether the first crawl happens before NewSiteMapper returns.
+	blockUntilFirstCrawl bool
+
+	// crawlInterval is the time between the scheduled crawls.
+	crawlInterval time.Duration
+
+	// crawlIntervalJitter is the maximum amount each scheduled crawl is moved by.
+	crawlIntervalJitter time.Duration
+
+	// cronSchedule determines when the scheduled crawls happen instead of the crawl interval.
+	// It's nil if no cron schedule was set.
+	cronSchedule *cronSchedule
+
+	// changeFreqs are the rules used to assign a <changefreq> to the URLs in the sitemap.
+	changeFreqs []changeFreqRule
+
+	// priorities are the rules used to assign a <priority> to the URLs in the sitemap.
+	priorities []priorityRule
+
+	// maxURLsPerSitemap is the maximum number of URLs in each file generated by GenerateSitemapIndex.
+	maxURLsPerSitemap int
+
+	// lastModFormat is the time layout used for the <lastmod> of the URLs in the sitemap.
+	lastModFormat string
+
+	// sitemapIndent is the indentation used for each level of the generated sitemaps.
+	sitemapIndent string
+
+	// sitemapOrdering determines the order of the URLs in the sitemap.
+	sitemapOrdering SitemapOrdering
+
+	// filterMode determines what happens to the URLs that match the filter pattern.
+	filterMode FilterMode
+
+	// alwaysIncludeRoot determines whether the root of the domain is always in the sitemap.
+	alwaysIncludeRoot bool
+
+	// autoPingURL is the URL of the sitemap that search engines are notified about after every
+	// successful crawl. Search engines aren't notified if it's empty.
+	autoPingURL string
+
+	// refreshSecret is the bearer token that RefreshHandler requires. Every request is accepted
+	// if it's empty.
+	refreshSecret string
+
+	// pingEndpoints are the search engine endpoints used by PingSearchEngines.
+	pingEndpoints []string
+}
+
+// Link is a page that was discovered while crawling the site.
+type Link struct {
+	// URL is the normalized URL of the page.
+	URL string
+
+	// Checksum is a SHA-256 hash of the page content, used to detect changes between crawls.
+	Checksum string
+
+	// LastChanged is the time at which a change to the page was last detected.
+	LastChanged time.Time
+
+	// LastSeen is the time at which the page was last fetched successfully.
+	LastSeen time.Time
+
+	// LastModified is the time from the page's Last-Modified header. It's the zero time if the
+	// server didn't send the header.
+	LastModified time.Time
+
+	// StatusCode is the HTTP status code of the latest response for the page. It's 304 when the
+	// page was unchanged according to a conditional request, and 0 for pages that were added with
+	// AddURL and haven't been crawled yet.
+	StatusCode int
+
+	// Size is the size in bytes of the page's body, after it has been decompressed. Unexpectedly
+	// small pages could be error templates while large pages might be worth optimizing.
+	Size int
 }
 
 // NewSiteMapper initializes and returns a new SiteMapper instance configured with the provided options.
 //
 // The SiteMapper starts its first crawl after the delay specified in `options.durationBeforeFirstCrawl`
-// and subsequently recrawls the site at intervals defined by `options.crawlInterval`.
+// and subsequently recrawls the site at intervals defined by `options.crawlInterval`. If
+// `options.blockUntilFirstCrawl` is set, the first crawl is performed before NewSiteMapper returns.
+// If `options.preflightCheck` is set as well and the starting URL can't be reached, the error is
+// logged and the first crawl still happens. Use NewSiteMapperWithError to get the error instead.
 //
 // Parameters:
 //
@@ -30,44 +168,343 @@ type SiteMapper struct {
 //
 //	*sitemapper.SiteMapper // A new SiteMapper instance.
 func NewSiteMapper(options *SiteMapperOptions) *SiteMapper {
+	mapper := newSiteMapper(options)
+	if err := mapper.preflight(options); err != nil {
+		mapper.spider.errorLogger(err)
+	}
+
+	mapper.start()
+
+	return mapper
+}
+
+// newSiteMapper creates the SiteMapper and its crawler from the options without starting to crawl.
+func newSiteMapper(options *SiteMapperOptions) *SiteMapper {
+	spider := newCrawler(options.domain, options.linkAttributes, options.infoLogger, options.errorLogger)
+	spider.jsonLinkAttributes = options.jsonLinkAttributes
+	spider.concurrency = options.concurrency
+	spider.maxDepth = options.maxDepth
+	spider.userAgent = options.userAgent
+	spider.httpClient = options.httpClient
+	spider.pageFetcher = options.pageFetcher
+	spider.linkExtractor = options.linkExtractor
+	spider.pageCallback = options.pageCallback
+	spider.proxyURL = options.proxyURL
+	spider.requestHeaders = options.requestHeaders.Clone()
+	spider.basicAuthUsername = options.basicAuthUsername
+	spider.basicAuthPassword = options.basicAuthPassword
+	spider.cookieJar = options.cookieJar
+	spider.cookies = options.cookies
+	spider.allowSubdomains = options.allowSubdomains
+	spider.ignoreScheme = options.ignoreScheme
+	spider.followRedirects = options.followRedirects
+	spider.alwaysUpdateLastMod = options.alwaysUpdateLastMod
+	spider.useConditionalRequests = options.useConditionalRequests
+	spider.requestTimeout = options.requestTimeout
+	spider.crawlDelay = options.crawlDelay
+	spider.maxCrawlDuration = options.maxCrawlDuration
+	spider.requestBudget = options.requestBudget
+	spider.fallbackThreshold = options.fallbackThreshold
+	spider.includePatterns = options.includePatterns
+	spider.excludePatterns = options.excludePatterns
+	spider.scopePatterns = options.scopePatterns
+	spider.pathCollapsePatterns = options.pathCollapsePatterns
+	spider.soft404Pattern = options.soft404Pattern
+	spider.changeDetectionMode = options.changeDetectionMode
+	spider.checksumIgnoreSelectors = options.checksumIgnoreSelectors
+	spider.trailingSlashPolicy = options.trailingSlashPolicy
+	spider.stripQueryParams = options.stripQueryParams
+	spider.ignoreQueryParams = options.ignoreQueryParams
+	spider.allowedContentTypes = options.allowedContentTypes
+	spider.maxResponseBytes = options.maxResponseBytes
+	spider.respectCanonical = options.respectCanonical
+	spider.respectNofollow = options.respectNofollow
+	spider.includeExternalImages = options.includeExternalImages
+	spider.includeExternalLinksInGraph = options.includeExternalLinksInGraph
+	spider.extractVideos = options.extractVideos
+	spider.metrics = options.metrics
+	spider.checkpointEvery = options.checkpointEvery
+	spider.respectRobotsTxt = options.respectRobotsTxt
+	spider.importLinkedSitemaps = options.importLinkedSitemaps
+
 	mapper := &SiteMapper{
-		spider:        newCrawler(options.domain, options.linkAttributes, options.infoLogger, options.errorLogger),
-		recrawlSignal: make(chan bool),
-		domain:        options.domain,
+		spider:         spider,
+		recrawlSignal:  make(chan bool),
+		done:           make(chan struct{}),
+		domain:         options.domain,
+		startingURL:    options.startingURL,
+		callbackFunc:   options.callbackFunc,
+		preCrawlFunc:   options.preCrawlFunc,
+		checkpointFunc: options.checkpointFunc,
+		changeFreqs:    slices.Clone(options.changeFreqs),
+		priorities:     slices.Clone(options.priorities),
+
+		durationBeforeFirstCrawl: options.durationBeforeFirstCrawl,
+		blockUntilFirstCrawl:     options.blockUntilFirstCrawl,
+		crawlInterval:            options.crawlInterval,
+		crawlIntervalJitter:      options.crawlIntervalJitter,
+		cronSchedule:             options.cronSchedule,
+
+		maxURLsPerSitemap: options.maxURLsPerSitemap,
+		lastModFormat:     options.lastModFormat,
+		sitemapIndent:     options.sitemapIndent,
+		sitemapOrdering:   options.sitemapOrdering,
+		filterMode:        options.filterMode,
+		alwaysIncludeRoot: options.alwaysIncludeRoot,
+		autoPingURL:       options.autoPingURL,
+		refreshSecret:     options.refreshSecret,
+		pingEndpoints:     searchEngineEndpoints,
+	}
+
+	spider.onCheckpoint = func() {
+		mapper.checkpointFunc(mapper)
+	}
+
+	return mapper
+}
+
+// start performs the first crawl if the caller wants to wait for it and starts the goroutine
+// that handles the scheduled and manual crawls. It only uses what newSiteMapper copied from the
+// options, since the caller is free to change the options afterwards.
+func (mapper *SiteMapper) start() {
+	// Perform the first crawl right away if the caller wants to wait for it.
+	if mapper.blockUntilFirstCrawl {
+		mapper.preCrawlFunc(mapper)
+		if err := mapper.spider.crawl(context.Background(), mapper.startingURL); err == nil {
+			mapper.autoPing()
+		}
 	}
 
 	// Start the crawling process in a separate goroutine.
 	go func() {
-		if options.durationBeforeFirstCrawl > 0 {
-			// Wait for the initial delay before the first crawl.
-			time.Sleep(options.durationBeforeFirstCrawl)
+		// The first crawl happens after the initial delay. The goroutine waits for it in the same
+		// select as the other signals so that it can accept manual recrawls in the meantime.
+		firstCrawl := time.NewTimer(mapper.durationBeforeFirstCrawl)
+		defer firstCrawl.Stop()
+
+		// nextCrawl is only set up after the first crawl. Receiving from the nil channel blocks
+		// until then. It's recreated after every scheduled crawl so that each one gets its own
+		// jitter or the next time from the cron schedule.
+		var nextCrawl *time.Timer
+		var tick <-chan time.Time
+
+		scheduleNextCrawl := func() {
+			delay := nextCrawlInterval(mapper.crawlInterval, mapper.crawlIntervalJitter)
+			if mapper.cronSchedule != nil {
+				delay = time.Until(mapper.cronSchedule.next(time.Now()))
+			} else if delay <= 0 {
+				// The options weren't validated. Crawling back to back would hammer the site, so
+				// only the manual crawls happen.
+				return
+			}
+
+			nextCrawl = time.NewTimer(delay)
+			tick = nextCrawl.C
 		}
 
-		// Perform the first crawl.
-		mapper.spider.crawl(options.startingURL)
+		if mapper.blockUntilFirstCrawl {
+			// The first crawl already happened in NewSiteMapper.
+			firstCrawl.Stop()
+			scheduleNextCrawl()
+		}
 
-		// Schedule periodic crawls using a ticker.
-		ticker := time.NewTicker(options.crawlInterval)
-		defer ticker.Stop()
+		defer func() {
+			if nextCrawl != nil {
+				nextCrawl.Stop()
+			}
+		}()
 
 		for {
 			select {
-			case <-ticker.C:
+			case <-firstCrawl.C:
+				// Perform the first crawl.
+				mapper.preCrawlFunc(mapper)
+				if err := mapper.spider.crawl(context.Background(), mapper.startingURL); err == nil {
+					mapper.autoPing()
+				}
+
+				// Schedule the periodic crawls.
+				scheduleNextCrawl()
+			case <-tick:
 				// Perform a scheduled crawl.
-				mapper.spider.crawl(options.startingURL)
-				options.callbackFunc(mapper)
+				mapper.preCrawlFunc(mapper)
+				err := mapper.spider.crawl(context.Background(), mapper.startingURL)
+				mapper.callbackFunc(mapper)
+
+				// Search engines are only notified about complete sitemaps.
+				if err == nil {
+					mapper.autoPing()
+				}
+
+				scheduleNextCrawl()
 			case <-mapper.recrawlSignal:
 				// Perform a manual recrawl triggered by the RecrawlSite method.
-				mapper.spider.crawl(options.startingURL)
-				options.callbackFunc(mapper)
+				mapper.preCrawlFunc(mapper)
+				err := mapper.spider.crawl(context.Background(), mapper.startingURL)
+				mapper.callbackFunc(mapper)
+
+				// Search engines are only notified about complete sitemaps.
+				if err == nil {
+					mapper.autoPing()
+				}
+			case <-mapper.done:
+				// Stop was called so we can shut down.
+				return
 			}
 		}
 	}()
+}
 
-	return mapper
+// nextCrawlInterval returns the time until the next scheduled crawl, which is the crawl interval
+// moved forwards or backwards by a random amount of up to jitter.
+func nextCrawlInterval(interval time.Duration, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return interval
+	}
+
+	next := interval + time.Duration(rand.Int64N(int64(jitter)*2+1)) - jitter
+	if next <= 0 {
+		// The options weren't validated so the jitter could exceed the interval.
+		return interval
+	}
+
+	return next
+}
+
+// NewSiteMapperWithError validates the options before initializing a new SiteMapper instance
+// the same way NewSiteMapper does. If the options are invalid the error from Validate is
+// returned and no SiteMapper is created. The same goes for the error from the preflight check
+// when it's turned on with SetPreflightCheck.
+func NewSiteMapperWithError(options *SiteMapperOptions) (*SiteMapper, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	mapper := newSiteMapper(options)
+	if err := mapper.preflight(options); err != nil {
+		return nil, err
+	}
+
+	mapper.start()
+
+	return mapper, nil
 }
 
 // RecrawlSite triggers a manual recrawl of the site, bypassing the scheduled interval.
-func (mapper *SiteMapper) RecrawlSite() {
-	mapper.recrawlSignal <- true
+//
+// RecrawlSite never blocks. The recrawl happens in the background goroutine so RecrawlSite
+// returns ErrCrawlInProgress if that goroutine is busy crawling the site, and ErrStopped
+// once the SiteMapper has been stopped.
+func (mapper *SiteMapper) RecrawlSite() error {
+	select {
+	case <-mapper.done:
+		return ErrStopped
+	default:
+	}
+
+	select {
+	case mapper.recrawlSignal <- true:
+		return nil
+	default:
+		return ErrCrawlInProgress
+	}
+}
+
+// Links returns all the links that have been discovered while crawling the site, sorted by URL.
+func (mapper *SiteMapper) Links() []Link {
+	crawlerURLs := mapper.spider.getLinks()
+
+	links := make([]Link, 0, len(crawlerURLs))
+	for _, crawlerURL := range crawlerURLs {
+		links = append(links, Link{
+			URL:          crawlerURL.link,
+			Checksum:     crawlerURL.checksum,
+			LastChanged:  crawlerURL.lastChanged,
+			LastSeen:     crawlerURL.lastSeen,
+			LastModified: crawlerURL.lastModified,
+			StatusCode:   crawlerURL.statusCode,
+			Size:         crawlerURL.size,
+		})
+	}
+
+	slices.SortFunc(links, func(a, b Link) int {
+		return strings.Compare(a.URL, b.URL)
+	})
+
+	return links
+}
+
+// SaveState writes the links that have been discovered so far, along with their checksums and
+// the times at which they last changed, to w as JSON.
+//
+// Loading the state with LoadState after a restart ensures that unchanged pages keep their
+// real last changed time instead of being reset to the time of the first crawl.
+func (mapper *SiteMapper) SaveState(w io.Writer) error {
+	return mapper.spider.saveState(w)
+}
+
+// LoadState replaces the discovered links with the ones previously written by SaveState. It
+// should be called before the first crawl, so set a duration before the first crawl that is
+// long enough or load the state right after creating the SiteMapper.
+func (mapper *SiteMapper) LoadState(r io.Reader) error {
+	return mapper.spider.loadState(r)
+}
+
+// CrawlWithContext crawls the site in the caller's goroutine and returns once the crawl has
+// finished or the context has been cancelled, whichever comes first.
+//
+// When the context is cancelled or its deadline passes, the in-flight requests are aborted and
+// the pages that were crawled up until that point are merged into the known links. The context's
+// error is returned in that case, or ErrMaxCrawlDuration if the crawl ran out of time. The
+// callback function is only called, and search engines are only notified, if the crawl completed.
+func (mapper *SiteMapper) CrawlWithContext(ctx context.Context) error {
+	mapper.preCrawlFunc(mapper)
+
+	if err := mapper.spider.crawl(ctx, mapper.startingURL); err != nil {
+		return err
+	}
+
+	mapper.callbackFunc(mapper)
+	mapper.autoPing()
+
+	return nil
+}
+
+// CrawlNow crawls the site in the caller's goroutine and returns once the crawl has finished,
+// so that the sitemap can be generated right after it returns. It's the same as calling
+// CrawlWithContext with a context that is never cancelled.
+//
+// CrawlNow doesn't go through the background goroutine so it works before the first scheduled
+// crawl and after Stop has been called. It waits for any crawl that is in progress to finish.
+func (mapper *SiteMapper) CrawlNow() error {
+	return mapper.CrawlWithContext(context.Background())
+}
+
+// ResumeCrawl continues the crawl that got interrupted from its latest checkpoint, in the
+// caller's goroutine, instead of crawling the whole site again. The pages that were crawled
+// before the checkpoint aren't fetched again. A new crawl is started if there is no checkpoint.
+//
+// Checkpoints are only taken when SetCheckpointEvery was used. They survive a restart if
+// the state was saved with SaveState and loaded with LoadState.
+func (mapper *SiteMapper) ResumeCrawl() error {
+	mapper.preCrawlFunc(mapper)
+
+	if err := mapper.spider.resume(context.Background(), mapper.startingURL); err != nil {
+		return err
+	}
+
+	mapper.callbackFunc(mapper)
+	mapper.autoPing()
+
+	return nil
+}
+
+// Stop terminates the background goroutine that crawls the site. Any crawl that is
+// currently in progress will be allowed to finish. Calling Stop more than once is safe.
+//
+// The sitemap can still be generated from the links that were found before Stop was called.
+func (mapper *SiteMapper) Stop() {
+	mapper.stopOnce.Do(func() {
+		close(mapper.done)
+	})
 }
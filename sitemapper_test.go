@@ -1,10 +1,14 @@
 package sitemapper
 
 import (
+	"context"
 	"encoding/xml"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"runtime"
 	"slices"
+	"sync"
 	"testing"
 	"time"
 )
@@ -66,7 +70,9 @@ func TestSiteMapperCrawling(t *testing.T) {
 		t.Error("Crawler found 'https://example.com' when it should not have")
 	}
 
-	mapper.RecrawlSite()
+	if err := mapper.RecrawlSite(); err != nil {
+		t.Error(err)
+	}
 
 	time.Sleep(time.Second * 1)
 
@@ -104,7 +110,7 @@ func TestSiteMapperSitemapGeneration(t *testing.T) {
 		t.Error(err)
 	}
 
-	if err := options.SetDurationBeforeFirstCrawl(0); err != nil {
+	if err := options.SetDurationBeforeFirstCrawl(time.Hour); err != nil {
 		t.Error(err)
 	}
 
@@ -117,8 +123,11 @@ func TestSiteMapperSitemapGeneration(t *testing.T) {
 	}
 
 	mapper := NewSiteMapper(options)
+	defer mapper.Stop()
 
-	time.Sleep(time.Second * 1)
+	if err := mapper.CrawlNow(); err != nil {
+		t.Fatal(err)
+	}
 
 	sitemap, err := mapper.GenerateSitemap(mockServer.URL, "/htmx")
 	if err != nil {
@@ -153,6 +162,308 @@ func TestSiteMapperSitemapGeneration(t *testing.T) {
 	}
 }
 
+func TestSiteMapperStop(t *testing.T) {
+	mockServer := httptest.NewServer(createMockServer())
+	defer mockServer.Close()
+
+	options := DefaultOptions()
+
+	if err := options.SetDomain(mockServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	if err := options.SetDurationBeforeFirstCrawl(0); err != nil {
+		t.Error(err)
+	}
+
+	goroutinesBefore := runtime.NumGoroutine()
+
+	mapper := NewSiteMapper(options)
+
+	time.Sleep(time.Second * 1)
+
+	mapper.Stop()
+
+	// Calling Stop twice shouldn't panic and RecrawlSite shouldn't block after stopping.
+	mapper.Stop()
+
+	if err := mapper.RecrawlSite(); !errors.Is(err, ErrStopped) {
+		t.Errorf("Expected RecrawlSite to return ErrStopped after stopping, got %v", err)
+	}
+
+	// Idle keep-alive connections from the crawl also hold onto goroutines.
+	http.DefaultTransport.(*http.Transport).CloseIdleConnections()
+
+	deadline := time.Now().Add(time.Second * 2)
+	for runtime.NumGoroutine() > goroutinesBefore && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	if goroutinesAfter := runtime.NumGoroutine(); goroutinesAfter > goroutinesBefore {
+		t.Errorf("Expected at most %d goroutines after Stop, got %d", goroutinesBefore, goroutinesAfter)
+	}
+
+	if len(mapper.spider.getLinks()) == 0 {
+		t.Error("Expected links from the crawl before Stop to be kept")
+	}
+}
+
+func TestSiteMapperStopBeforeFirstCrawl(t *testing.T) {
+	options := DefaultOptions()
+
+	if err := options.SetDurationBeforeFirstCrawl(time.Hour); err != nil {
+		t.Error(err)
+	}
+
+	goroutinesBefore := runtime.NumGoroutine()
+
+	mapper := NewSiteMapper(options)
+	mapper.Stop()
+
+	deadline := time.Now().Add(time.Second * 2)
+	for runtime.NumGoroutine() > goroutinesBefore && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	if goroutinesAfter := runtime.NumGoroutine(); goroutinesAfter > goroutinesBefore {
+		t.Errorf("Expected at most %d goroutines after Stop, got %d", goroutinesBefore, goroutinesAfter)
+	}
+}
+
+func TestSiteMapperBlockUntilFirstCrawl(t *testing.T) {
+	mockServer := httptest.NewServer(createMockServer())
+	defer mockServer.Close()
+
+	options := DefaultOptions()
+
+	if err := options.SetDomain(mockServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	options.SetBlockUntilFirstCrawl(true)
+
+	mapper := NewSiteMapper(options)
+	defer mapper.Stop()
+
+	// The links should be there without waiting for the background goroutine.
+	if len(mapper.Links()) == 0 {
+		t.Error("Expected the first crawl to have finished when NewSiteMapper returned")
+	}
+
+	// The background goroutine should still accept recrawls.
+	err := mapper.RecrawlSite()
+
+	deadline := time.Now().Add(time.Second * 2)
+	for errors.Is(err, ErrCrawlInProgress) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+		err = mapper.RecrawlSite()
+	}
+
+	if err != nil {
+		t.Errorf("Expected RecrawlSite to be accepted after the first crawl, got %v", err)
+	}
+}
+
+func TestSiteMapperRecrawlSiteInProgress(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	var once sync.Once
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			close(started)
+		})
+
+		<-release
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body>Hello</body></html>`))
+	}))
+	defer mockServer.Close()
+
+	options := DefaultOptions()
+
+	if err := options.SetDomain(mockServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	if err := options.SetDurationBeforeFirstCrawl(0); err != nil {
+		t.Error(err)
+	}
+
+	mapper := NewSiteMapper(options)
+	defer mapper.Stop()
+
+	<-started
+
+	// The first crawl is still waiting for the server so RecrawlSite shouldn't block.
+	if err := mapper.RecrawlSite(); !errors.Is(err, ErrCrawlInProgress) {
+		t.Errorf("Expected RecrawlSite to return ErrCrawlInProgress during a crawl, got %v", err)
+	}
+
+	close(release)
+}
+
+func TestSiteMapperRecrawlSiteBeforeFirstCrawl(t *testing.T) {
+	mockServer := httptest.NewServer(createMockServer())
+	defer mockServer.Close()
+
+	options := DefaultOptions()
+
+	if err := options.SetDomain(mockServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	if err := options.SetDurationBeforeFirstCrawl(time.Hour); err != nil {
+		t.Error(err)
+	}
+
+	crawled := make(chan struct{}, 1)
+	options.SetCallbackFunction(func(mapper *SiteMapper) {
+		crawled <- struct{}{}
+	})
+
+	mapper := NewSiteMapper(options)
+	defer mapper.Stop()
+
+	// A recrawl should be accepted while waiting for the first crawl, once the background
+	// goroutine is up and running.
+	err := mapper.RecrawlSite()
+
+	deadline := time.Now().Add(time.Second * 2)
+	for errors.Is(err, ErrCrawlInProgress) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+		err = mapper.RecrawlSite()
+	}
+
+	if err != nil {
+		t.Fatalf("Expected RecrawlSite to be accepted before the first crawl, got %v", err)
+	}
+
+	select {
+	case <-crawled:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Expected the site to be recrawled")
+	}
+
+	if len(mapper.Links()) == 0 {
+		t.Error("Expected the recrawl to find links")
+	}
+}
+
+func TestSiteMapperCrawlWithContext(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/fast">Fast</a><a href="/slow">Slow</a>`))
+	})
+	mux.HandleFunc("GET /fast", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<h1>Fast</h1>"))
+	})
+	mux.HandleFunc("GET /slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second * 10):
+		}
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	options := DefaultOptions()
+
+	if err := options.SetDomain(mockServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	if err := options.SetDurationBeforeFirstCrawl(time.Hour); err != nil {
+		t.Error(err)
+	}
+
+	mapper := NewSiteMapper(options)
+	defer mapper.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*500)
+	defer cancel()
+
+	start := time.Now()
+	err := mapper.CrawlWithContext(ctx)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second*2 {
+		t.Errorf("Expected the crawl to stop promptly, took %v", elapsed)
+	}
+
+	linksFound := []string{}
+	for _, link := range mapper.spider.getLinks() {
+		linksFound = append(linksFound, link.link)
+	}
+
+	for _, link := range []string{mockServer.URL, mockServer.URL + "/fast"} {
+		if !slices.Contains(linksFound, link) {
+			t.Errorf("Expected the partial crawl to contain %s", link)
+		}
+	}
+}
+
+func TestSiteMapperLinks(t *testing.T) {
+	mockServer := httptest.NewServer(createMockServer())
+	defer mockServer.Close()
+
+	options := DefaultOptions()
+
+	if err := options.SetDomain(mockServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	if err := options.SetDurationBeforeFirstCrawl(time.Hour); err != nil {
+		t.Error(err)
+	}
+
+	if err := options.SetLinkAttributes("hx-get"); err != nil {
+		t.Error(err)
+	}
+
+	mapper := NewSiteMapper(options)
+	defer mapper.Stop()
+
+	if err := mapper.CrawlWithContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	links := mapper.Links()
+
+	expected := []string{mockServer.URL, mockServer.URL + "/htmx", mockServer.URL + "/page1", mockServer.URL + "/page2"}
+	if len(links) != len(expected) {
+		t.Fatalf("Expected %d links, got %d", len(expected), len(links))
+	}
+
+	for i, link := range links {
+		if link.URL != expected[i] {
+			t.Errorf("Expected link %d to be '%s', got '%s'", i, expected[i], link.URL)
+		}
+
+		if link.Checksum == "" {
+			t.Errorf("Expected '%s' to have a checksum", link.URL)
+		}
+
+		if link.LastChanged.IsZero() {
+			t.Errorf("Expected '%s' to have a last changed time", link.URL)
+		}
+
+		if link.StatusCode != http.StatusOK {
+			t.Errorf("Expected '%s' to have status code %d, got %d", link.URL, http.StatusOK, link.StatusCode)
+		}
+
+		if link.Size <= 0 {
+			t.Errorf("Expected '%s' to have a size, got %d", link.URL, link.Size)
+		}
+	}
+}
+
 func createMockServer() *http.ServeMux {
 	mux := http.NewServeMux()
 
@@ -239,3 +550,147 @@ func extractURLsFromSitemap(sitemap string) ([]string, error) {
 
 	return urls, nil
 }
+
+func TestNextCrawlInterval(t *testing.T) {
+	if next := nextCrawlInterval(time.Hour, 0); next != time.Hour {
+		t.Errorf("Expected no jitter to keep the interval at %v, got %v", time.Hour, next)
+	}
+
+	jittered := false
+	for range 100 {
+		next := nextCrawlInterval(time.Hour, time.Minute)
+		if next < time.Hour-time.Minute || next > time.Hour+time.Minute {
+			t.Fatalf("Expected the interval to be within a minute of %v, got %v", time.Hour, next)
+		}
+
+		if next != time.Hour {
+			jittered = true
+		}
+	}
+
+	if !jittered {
+		t.Error("Expected the jitter to change the interval")
+	}
+
+	if next := nextCrawlInterval(time.Second, time.Hour); next <= 0 {
+		t.Errorf("Expected the interval to stay positive, got %v", next)
+	}
+}
+
+func TestSiteMapperZeroCrawlInterval(t *testing.T) {
+	var mutex sync.Mutex
+	requests := 0
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests++
+		mutex.Unlock()
+	}))
+	defer mockServer.Close()
+
+	options := DefaultOptions()
+
+	if err := options.SetDomain(mockServer.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := options.SetCrawlInterval(0); err != nil {
+		t.Fatal(err)
+	}
+
+	options.SetBlockUntilFirstCrawl(true)
+
+	// NewSiteMapper doesn't validate the options, so the interval of 0 reaches the scheduler.
+	mapper := NewSiteMapper(options)
+	defer mapper.Stop()
+
+	time.Sleep(time.Millisecond * 100)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if requests != 1 {
+		t.Errorf("Expected only the first crawl to happen, got %d requests", requests)
+	}
+}
+
+func TestNewSiteMapperWithError(t *testing.T) {
+	options := DefaultOptions()
+
+	if err := options.SetCrawlInterval(0); err != nil {
+		t.Fatal(err)
+	}
+
+	mapper, err := NewSiteMapperWithError(options)
+	if err == nil {
+		mapper.Stop()
+		t.Fatal("Expected an error for a zero crawl interval, got nil")
+	}
+
+	if err := options.SetCrawlInterval(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := options.SetDurationBeforeFirstCrawl(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	mapper, err = NewSiteMapperWithError(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mapper.Stop()
+}
+
+func TestSiteMapperPreCrawlCallback(t *testing.T) {
+	var mutex sync.Mutex
+	var calls []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		calls = append(calls, "crawl")
+		mutex.Unlock()
+	})
+
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	options := DefaultOptions()
+
+	if err := options.SetDomain(mockServer.URL); err != nil {
+		t.Error(err)
+	}
+
+	if err := options.SetDurationBeforeFirstCrawl(time.Hour); err != nil {
+		t.Error(err)
+	}
+
+	options.SetPreCrawlCallback(func(mapper *SiteMapper) {
+		mutex.Lock()
+		calls = append(calls, "pre")
+		mutex.Unlock()
+	})
+
+	options.SetCallbackFunction(func(mapper *SiteMapper) {
+		mutex.Lock()
+		calls = append(calls, "post")
+		mutex.Unlock()
+	})
+
+	mapper := NewSiteMapper(options)
+	defer mapper.Stop()
+
+	if err := mapper.CrawlWithContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	expected := []string{"pre", "crawl", "post"}
+	if !slices.Equal(calls, expected) {
+		t.Errorf("Expected the calls to be %v, got %v", expected, calls)
+	}
+}
@@ -0,0 +1,227 @@
+package sitemapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// crawlerState is the JSON representation of the crawler's state that gets persisted
+// across restarts.
+type crawlerState struct {
+	Links      []stateLink      `json:"links"`
+	Checkpoint *stateCheckpoint `json:"checkpoint,omitempty"`
+}
+
+// stateLink is the JSON representation of a crawlerURL.
+type stateLink struct {
+	URL           string           `json:"url"`
+	Checksum      string           `json:"checksum"`
+	LastChanged   time.Time        `json:"lastChanged"`
+	LastSeen      time.Time        `json:"lastSeen"`
+	LastModified  time.Time        `json:"lastModified"`
+	ETag          string           `json:"etag,omitempty"`
+	StatusCode    int              `json:"statusCode,omitempty"`
+	Size          int              `json:"size,omitempty"`
+	OutLinks      []string         `json:"outLinks"`
+	ExternalLinks []string         `json:"externalLinks,omitempty"`
+	Noindex       bool             `json:"noindex,omitempty"`
+	Soft404       bool             `json:"soft404,omitempty"`
+	Images        []string         `json:"images,omitempty"`
+	Alternates    []stateAlternate `json:"alternates,omitempty"`
+	Videos        []stateVideo     `json:"videos,omitempty"`
+	CrawlOrder    int              `json:"crawlOrder,omitempty"`
+	Manual        bool             `json:"manual,omitempty"`
+}
+
+// stateAlternate is the JSON representation of an alternateLink.
+type stateAlternate struct {
+	Hreflang string `json:"hreflang"`
+	Href     string `json:"href"`
+}
+
+// stateVideo is the JSON representation of a videoInfo.
+type stateVideo struct {
+	ContentURL   string `json:"contentUrl"`
+	ThumbnailURL string `json:"thumbnailUrl,omitempty"`
+	Title        string `json:"title,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// stateCheckpoint is the JSON representation of a crawlCheckpoint.
+type stateCheckpoint struct {
+	Queue   []stateQueueItem `json:"queue"`
+	Visited []stateLink      `json:"visited"`
+}
+
+// stateQueueItem is the JSON representation of a crawlItem.
+type stateQueueItem struct {
+	URL      string `json:"url"`
+	Depth    int    `json:"depth"`
+	Referrer string `json:"referrer,omitempty"`
+}
+
+// saveState writes the known links, and the checkpoint of an unfinished crawl if there is
+// one, to w as JSON.
+func (crawler *crawler) saveState(w io.Writer) error {
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	state := crawlerState{
+		Links: make([]stateLink, 0, len(crawler.links)),
+	}
+
+	for _, link := range crawler.links {
+		state.Links = append(state.Links, newStateLink(link))
+	}
+
+	if crawler.checkpoint != nil {
+		state.Checkpoint = &stateCheckpoint{
+			Queue:   make([]stateQueueItem, 0, len(crawler.checkpoint.queue)),
+			Visited: make([]stateLink, 0, len(crawler.checkpoint.visited)),
+		}
+
+		for _, item := range crawler.checkpoint.queue {
+			state.Checkpoint.Queue = append(state.Checkpoint.Queue, stateQueueItem{URL: item.link, Depth: item.depth, Referrer: item.referrer})
+		}
+
+		for _, link := range crawler.checkpoint.visited {
+			state.Checkpoint.Visited = append(state.Checkpoint.Visited, newStateLink(link))
+		}
+	}
+
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	return nil
+}
+
+// loadState replaces the known links, and the checkpoint, with the ones read from r.
+func (crawler *crawler) loadState(r io.Reader) error {
+	var state crawlerState
+
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	links := make(map[string]crawlerURL, len(state.Links))
+	for _, link := range state.Links {
+		if link.URL == "" {
+			continue
+		}
+
+		links[link.URL] = link.crawlerURL()
+	}
+
+	var checkpoint *crawlCheckpoint
+	if state.Checkpoint != nil {
+		checkpoint = &crawlCheckpoint{
+			visited: make(map[string]crawlerURL, len(state.Checkpoint.Visited)),
+		}
+
+		for _, item := range state.Checkpoint.Queue {
+			if item.URL != "" {
+				checkpoint.queue = append(checkpoint.queue, crawlItem{link: item.URL, depth: item.Depth, referrer: item.Referrer})
+			}
+		}
+
+		for _, link := range state.Checkpoint.Visited {
+			if link.URL != "" {
+				checkpoint.visited[link.URL] = link.crawlerURL()
+			}
+		}
+	}
+
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	crawler.links = links
+	crawler.checkpoint = checkpoint
+
+	return nil
+}
+
+// newStateLink converts a crawlerURL into its JSON representation.
+func newStateLink(link crawlerURL) stateLink {
+	var alternates []stateAlternate
+	for _, alternate := range link.alternates {
+		alternates = append(alternates, stateAlternate{Hreflang: alternate.hreflang, Href: alternate.href})
+	}
+
+	var videos []stateVideo
+	for _, video := range link.videos {
+		videos = append(videos, stateVideo{
+			ContentURL:   video.contentURL,
+			ThumbnailURL: video.thumbnailURL,
+			Title:        video.title,
+			Description:  video.description,
+		})
+	}
+
+	return stateLink{
+		URL:           link.link,
+		Checksum:      link.checksum,
+		LastChanged:   link.lastChanged,
+		LastSeen:      link.lastSeen,
+		LastModified:  link.lastModified,
+		ETag:          link.etag,
+		StatusCode:    link.statusCode,
+		Size:          link.size,
+		OutLinks:      link.outLinks,
+		ExternalLinks: link.externalLinks,
+		Noindex:       link.noindex,
+		Soft404:       link.soft404,
+		Images:        link.images,
+		Alternates:    alternates,
+		Videos:        videos,
+		CrawlOrder:    link.crawlOrder,
+		Manual:        link.manual,
+	}
+}
+
+// crawlerURL converts the JSON representation back into a crawlerURL.
+func (link stateLink) crawlerURL() crawlerURL {
+	// States saved before the last seen time was tracked only have the last changed time, which
+	// is the closest thing to it.
+	lastSeen := link.LastSeen
+	if lastSeen.IsZero() {
+		lastSeen = link.LastChanged
+	}
+
+	var alternates []alternateLink
+	for _, alternate := range link.Alternates {
+		alternates = append(alternates, alternateLink{hreflang: alternate.Hreflang, href: alternate.Href})
+	}
+
+	var videos []videoInfo
+	for _, video := range link.Videos {
+		videos = append(videos, videoInfo{
+			contentURL:   video.ContentURL,
+			thumbnailURL: video.ThumbnailURL,
+			title:        video.Title,
+			description:  video.Description,
+		})
+	}
+
+	return crawlerURL{
+		link:          link.URL,
+		checksum:      link.Checksum,
+		lastChanged:   link.LastChanged,
+		lastSeen:      lastSeen,
+		lastModified:  link.LastModified,
+		etag:          link.ETag,
+		statusCode:    link.StatusCode,
+		size:          link.Size,
+		outLinks:      link.OutLinks,
+		externalLinks: link.ExternalLinks,
+		noindex:       link.Noindex,
+		soft404:       link.Soft404,
+		images:        link.Images,
+		alternates:    alternates,
+		videos:        videos,
+		crawlOrder:    link.CrawlOrder,
+		manual:        link.Manual,
+	}
+}
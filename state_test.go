@@ -0,0 +1,81 @@
+package sitemapper
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveAndLoadState(t *testing.T) {
+	lastChanged := time.Date(2024, time.May, 1, 10, 30, 0, 0, time.UTC)
+
+	c := newCrawler("http://example.com", nil, nil, nil)
+	c.links["http://example.com/page"] = crawlerURL{
+		link:        "http://example.com/page",
+		checksum:    "abc123",
+		lastChanged: lastChanged,
+	}
+
+	var buffer bytes.Buffer
+
+	if err := c.saveState(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := newCrawler("http://example.com", nil, nil, nil)
+
+	if err := loaded.loadState(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	link, has := loaded.links["http://example.com/page"]
+	if !has {
+		t.Fatal("Expected the loaded state to contain 'http://example.com/page'")
+	}
+
+	if link.checksum != "abc123" || !link.lastChanged.Equal(lastChanged) {
+		t.Errorf("Expected the loaded link to match the saved link, got %+v", link)
+	}
+
+	if err := loaded.loadState(strings.NewReader("not json")); err == nil {
+		t.Error("Expected an error when loading invalid state")
+	}
+}
+
+func TestLoadStateKeepsLastChanged(t *testing.T) {
+	mockServer := httptest.NewServer(createMockServer())
+	defer mockServer.Close()
+
+	// Crawl the site once and save the state.
+	c := newCrawler(mockServer.URL, []string{"hx-get"}, func(string) {}, func(error) {})
+	c.crawl(context.Background(), "/")
+
+	var buffer bytes.Buffer
+
+	if err := c.saveState(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := make(map[string]crawlerURL)
+	for _, link := range c.getLinks() {
+		saved[link.link] = link
+	}
+
+	// A "restarted" crawler should keep the last changed times of the unchanged pages.
+	restarted := newCrawler(mockServer.URL, []string{"hx-get"}, func(string) {}, func(error) {})
+
+	if err := restarted.loadState(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted.crawl(context.Background(), "/")
+
+	for _, link := range restarted.getLinks() {
+		if !link.lastChanged.Equal(saved[link.link].lastChanged) {
+			t.Errorf("Expected '%s' to keep its last changed time %v, got %v", link.link, saved[link.link].lastChanged, link.lastChanged)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package sitemapper
+
+import "time"
+
+// CrawlStats describes the outcome of the latest crawl.
+type CrawlStats struct {
+	// PagesCrawled is the number of pages that were fetched successfully.
+	PagesCrawled int
+
+	// Errors is the number of errors that occurred, like failed requests or unexpected status codes.
+	Errors int
+
+	// NewLinks is the number of pages that weren't known before the crawl.
+	NewLinks int
+
+	// ChangedLinks is the number of known pages whose content changed since the previous crawl.
+	ChangedLinks int
+
+	// Duration is how long the crawl took.
+	Duration time.Duration
+
+	// LastCrawlTime is the time at which the crawl finished.
+	LastCrawlTime time.Time
+}
+
+// Stats returns the statistics of the latest crawl. The zero value is returned if no crawl
+// has finished yet.
+func (mapper *SiteMapper) Stats() CrawlStats {
+	return mapper.spider.getStats()
+}
+
+// LastCrawlTime returns the time at which the latest successful crawl finished. The zero value
+// is returned if no crawl has finished successfully yet, so that callers can show the sitemap
+// as never having been updated.
+func (mapper *SiteMapper) LastCrawlTime() time.Time {
+	return mapper.spider.getLastCrawlTime()
+}
+
+// getLastCrawlTime retrieves the time at which the latest successful crawl finished.
+func (crawler *crawler) getLastCrawlTime() time.Time {
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	return crawler.lastCrawlTime
+}
+
+// getStats retrieves the statistics of the latest crawl.
+func (crawler *crawler) getStats() CrawlStats {
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	return crawler.stats
+}
+
+// logCrawlError counts the error that occurred while crawling the link towards the current
+// crawl's statistics before logging it and emitting a PageError event.
+func (crawler *crawler) logCrawlError(link string, err error) {
+	crawler.mutex.Lock()
+	crawler.crawlErrors++
+	crawler.mutex.Unlock()
+
+	crawler.metrics.IncCrawlErrors()
+	crawler.errorLogger(err)
+	crawler.emit(CrawlEvent{Type: PageError, URL: link, Err: err})
+}
@@ -0,0 +1,78 @@
+package sitemapper
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+type videoSitemapVideo struct {
+	XMLName      xml.Name `xml:"video:video"`
+	ThumbnailLoc string   `xml:"video:thumbnail_loc,omitempty"`
+	Title        string   `xml:"video:title"`
+	Description  string   `xml:"video:description"`
+	ContentLoc   string   `xml:"video:content_loc"`
+}
+
+type videoSitemapURL struct {
+	XMLName      xml.Name            `xml:"url"`
+	Location     string              `xml:"loc"`
+	LastModified string              `xml:"lastmod,omitempty"`
+	Videos       []videoSitemapVideo `xml:"video:video"`
+}
+
+type videoSitemapURLSet struct {
+	XMLName    xml.Name          `xml:"urlset"`
+	Xmlns      string            `xml:"xmlns,attr"`
+	XmlnsVideo string            `xml:"xmlns:video,attr"`
+	URLS       []videoSitemapURL `xml:"url"`
+}
+
+// GenerateVideoSitemap generates a sitemap using the video sitemap extension. Every page that
+// embeds videos gets a <url> entry with a <video:video> entry for each of its videos, which helps
+// search engines show them in video search results.
+//
+// Videos are only collected when SetExtractVideos is enabled. Pages with a noindex robots meta
+// tag and soft 404 pages are skipped. The crawled domain is replaced with baseDomain the same way
+// GenerateSitemap does it.
+func (mapper *SiteMapper) GenerateVideoSitemap(baseDomain string) (string, error) {
+	urlSet := videoSitemapURLSet{
+		Xmlns:      "http://www.sitemaps.org/schemas/sitemap/0.9",
+		XmlnsVideo: "http://www.google.com/schemas/sitemap-video/1.1",
+	}
+
+	for _, link := range mapper.orderedLinks() {
+		if !link.indexable() || len(link.videos) == 0 {
+			continue
+		}
+
+		url := videoSitemapURL{
+			Location:     replaceDomain(link.link, mapper.domain, baseDomain),
+			LastModified: mapper.formatLastMod(link.lastMod()),
+		}
+
+		for _, video := range link.videos {
+			url.Videos = append(url.Videos, videoSitemapVideo{
+				ThumbnailLoc: replaceDomain(video.thumbnailURL, mapper.domain, baseDomain),
+				Title:        video.title,
+				Description:  video.description,
+				ContentLoc:   replaceDomain(video.contentURL, mapper.domain, baseDomain),
+			})
+		}
+
+		urlSet.URLS = append(urlSet.URLS, url)
+	}
+
+	var builder strings.Builder
+
+	builder.WriteString(xmlDeclaration)
+
+	encoder := xml.NewEncoder(&builder)
+	encoder.Indent("", mapper.sitemapIndent)
+
+	if err := encoder.Encode(urlSet); err != nil {
+		return "", fmt.Errorf("failed to generate xml: %w", err)
+	}
+
+	return builder.String(), nil
+}
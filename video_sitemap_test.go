@@ -0,0 +1,49 @@
+package sitemapper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateVideoSitemap(t *testing.T) {
+	mapper := newTestSiteMapper(
+		crawlerURL{link: "http://example.com/tutorial", videos: []videoInfo{
+			{contentURL: "http://example.com/videos/intro.mp4", thumbnailURL: "http://example.com/thumbs/intro.jpg", title: "Intro", description: "Getting started"},
+			{contentURL: "https://cdn.example.org/advanced.mp4", title: "Advanced & more", description: "Advanced & more"},
+		}},
+		crawlerURL{link: "http://example.com/about"},
+		crawlerURL{link: "http://example.com/private", videos: []videoInfo{{contentURL: "http://example.com/videos/private.mp4", title: "Private"}}, noindex: true},
+	)
+
+	sitemap, err := mapper.GenerateVideoSitemap("https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`xmlns:video="http://www.google.com/schemas/sitemap-video/1.1"`,
+		"<loc>https://example.com/tutorial</loc>",
+		"<video:thumbnail_loc>https://example.com/thumbs/intro.jpg</video:thumbnail_loc>",
+		"<video:title>Intro</video:title>",
+		"<video:description>Getting started</video:description>",
+		"<video:content_loc>https://example.com/videos/intro.mp4</video:content_loc>",
+		"<video:title>Advanced &amp; more</video:title>",
+		"<video:content_loc>https://cdn.example.org/advanced.mp4</video:content_loc>",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(sitemap, e) {
+			t.Errorf("Expected video sitemap to contain '%s', got:\n%s", e, sitemap)
+		}
+	}
+
+	for _, unexpected := range []string{"/about", "private"} {
+		if strings.Contains(sitemap, unexpected) {
+			t.Errorf("Expected video sitemap to not contain '%s', got:\n%s", unexpected, sitemap)
+		}
+	}
+
+	if strings.Count(sitemap, "<video:thumbnail_loc>") != 1 {
+		t.Errorf("Expected only the video with a poster to have a thumbnail, got:\n%s", sitemap)
+	}
+}
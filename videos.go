@@ -0,0 +1,160 @@
+package sitemapper
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// videoInfo is a video embedded in a page through a <video> tag.
+type videoInfo struct {
+	// contentURL is the absolute URL of the video file, from the src of the <video> tag or of
+	// its first <source> tag.
+	contentURL string
+
+	// thumbnailURL is the absolute URL of the video's poster image. It's empty if there is none.
+	thumbnailURL string
+
+	// title is the title of the video. It's taken from the title or aria-label of the <video>
+	// tag, the heading closest before the video or the title of the page, in that order.
+	title string
+
+	// description is the description of the video. It's the page's meta description, or the
+	// title of the video if the page doesn't have one.
+	description string
+}
+
+// videoCollector collects the videos of a page while it's being tokenized, along with the
+// surrounding markup that their titles and descriptions are taken from.
+type videoCollector struct {
+	crawler *crawler
+
+	// videos are the videos that have been found so far.
+	videos []videoInfo
+
+	// current is the video whose <source> tags are being read. It's nil outside of <video> tags.
+	current *videoInfo
+
+	// heading is the text of the latest heading and headingTag is the tag of the heading that is
+	// being read, if any.
+	heading    string
+	headingTag string
+	headingBuf strings.Builder
+
+	// title is the text of the page's <title> tag and description is its meta description.
+	title       string
+	inTitle     bool
+	description string
+}
+
+// startTag handles a start or self-closing tag.
+func (collector *videoCollector) startTag(token html.Token, selfClosing bool, base *url.URL) {
+	switch token.Data {
+	case "video":
+		collector.finishVideo()
+
+		video := videoInfo{}
+
+		if src, ok := getAttr(token, "src"); ok {
+			video.contentURL, _ = collector.crawler.absoluteURL(src, base)
+		}
+
+		if poster, ok := getAttr(token, "poster"); ok {
+			video.thumbnailURL, _ = collector.crawler.absoluteURL(poster, base)
+		}
+
+		if title, ok := getAttr(token, "title"); ok && strings.TrimSpace(title) != "" {
+			video.title = strings.TrimSpace(title)
+		} else if label, ok := getAttr(token, "aria-label"); ok {
+			video.title = strings.TrimSpace(label)
+		}
+
+		collector.current = &video
+
+		if selfClosing {
+			collector.finishVideo()
+		}
+	case "source":
+		// Only the first playable source of the video is used.
+		if collector.current != nil && collector.current.contentURL == "" {
+			if src, ok := getAttr(token, "src"); ok {
+				collector.current.contentURL, _ = collector.crawler.absoluteURL(src, base)
+			}
+		}
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		collector.headingTag = token.Data
+		collector.headingBuf.Reset()
+	case "title":
+		collector.inTitle = !selfClosing
+	case "meta":
+		if name, ok := getAttr(token, "name"); ok && strings.EqualFold(strings.TrimSpace(name), "description") {
+			if content, ok := getAttr(token, "content"); ok && collector.description == "" {
+				collector.description = strings.TrimSpace(content)
+			}
+		}
+	}
+}
+
+// endTag handles an end tag.
+func (collector *videoCollector) endTag(name string) {
+	switch name {
+	case "video":
+		collector.finishVideo()
+	case collector.headingTag:
+		collector.heading = strings.Join(strings.Fields(collector.headingBuf.String()), " ")
+		collector.headingTag = ""
+	case "title":
+		collector.inTitle = false
+	}
+}
+
+// text handles the text between tags.
+func (collector *videoCollector) text(data string) {
+	if collector.headingTag != "" {
+		collector.headingBuf.WriteString(data)
+	}
+
+	if collector.inTitle && collector.title == "" {
+		collector.title = strings.Join(strings.Fields(data), " ")
+	}
+}
+
+// finishVideo adds the video that is being read to the videos, as long as a video file was
+// found for it.
+func (collector *videoCollector) finishVideo() {
+	video := collector.current
+	collector.current = nil
+
+	if video == nil || video.contentURL == "" {
+		return
+	}
+
+	if video.title == "" {
+		video.title = collector.heading
+	}
+
+	if !slices.ContainsFunc(collector.videos, func(v videoInfo) bool { return v.contentURL == video.contentURL }) {
+		collector.videos = append(collector.videos, *video)
+	}
+}
+
+// finish returns the videos of the page once it has been read entirely, with the titles and
+// descriptions that could only be filled in from the rest of the page.
+func (collector *videoCollector) finish() []videoInfo {
+	collector.finishVideo()
+
+	for i := range collector.videos {
+		if collector.videos[i].title == "" {
+			collector.videos[i].title = collector.title
+		}
+
+		collector.videos[i].description = collector.description
+		if collector.videos[i].description == "" {
+			collector.videos[i].description = collector.videos[i].title
+		}
+	}
+
+	return collector.videos
+}
@@ -0,0 +1,548 @@
+package sitemapper
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Option configures SiteMapperOptions. Options are passed to NewSiteMapperWithOptions and
+// can return an error when the value they were given is invalid.
+//
+// Each Option mirrors one of the setters on SiteMapperOptions. For example:
+//
+//	mapper, err := sitemapper.NewSiteMapperWithOptions(
+//		sitemapper.WithDomain("https://example.com"),
+//		sitemapper.WithCrawlInterval(time.Hour*24),
+//		sitemapper.WithConcurrency(4),
+//	)
+type Option func(options *SiteMapperOptions) error
+
+// NewSiteMapperWithOptions initializes and returns a new SiteMapper instance configured by
+// applying the given options, in order, on top of DefaultOptions.
+//
+// The first option that fails stops the configuration and its error is returned without
+// creating a SiteMapper. The resulting options are checked with Validate as well.
+func NewSiteMapperWithOptions(opts ...Option) (*SiteMapper, error) {
+	options := DefaultOptions()
+
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewSiteMapperWithError(options)
+}
+
+// WithDomain is the Option equivalent of SiteMapperOptions.SetDomain.
+func WithDomain(domain string) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetDomain(domain)
+	}
+}
+
+// WithAllowSubdomains is the Option equivalent of SiteMapperOptions.SetAllowSubdomains.
+func WithAllowSubdomains(allow bool) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetAllowSubdomains(allow)
+		return nil
+	}
+}
+
+// WithIgnoreScheme is the Option equivalent of SiteMapperOptions.SetIgnoreScheme.
+func WithIgnoreScheme(ignore bool) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetIgnoreScheme(ignore)
+		return nil
+	}
+}
+
+// WithDurationBeforeFirstCrawl is the Option equivalent of SiteMapperOptions.SetDurationBeforeFirstCrawl.
+func WithDurationBeforeFirstCrawl(duration time.Duration) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetDurationBeforeFirstCrawl(duration)
+	}
+}
+
+// WithBlockUntilFirstCrawl is the Option equivalent of SiteMapperOptions.SetBlockUntilFirstCrawl.
+func WithBlockUntilFirstCrawl(block bool) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetBlockUntilFirstCrawl(block)
+		return nil
+	}
+}
+
+// WithPreflightCheck is the Option equivalent of SiteMapperOptions.SetPreflightCheck.
+func WithPreflightCheck(check bool) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetPreflightCheck(check)
+		return nil
+	}
+}
+
+// WithCrawlInterval is the Option equivalent of SiteMapperOptions.SetCrawlInterval.
+func WithCrawlInterval(interval time.Duration) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetCrawlInterval(interval)
+	}
+}
+
+// WithCrawlIntervalJitter is the Option equivalent of SiteMapperOptions.SetCrawlIntervalJitter.
+func WithCrawlIntervalJitter(jitter time.Duration) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetCrawlIntervalJitter(jitter)
+	}
+}
+
+// WithCronSchedule is the Option equivalent of SiteMapperOptions.SetCronSchedule.
+func WithCronSchedule(spec string) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetCronSchedule(spec)
+	}
+}
+
+// WithStartingURL is the Option equivalent of SiteMapperOptions.SetStartingURL.
+func WithStartingURL(urlPath string) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetStartingURL(urlPath)
+	}
+}
+
+// WithLinkAttributes is the Option equivalent of SiteMapperOptions.SetLinkAttributes.
+func WithLinkAttributes(attributes ...string) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetLinkAttributes(attributes...)
+	}
+}
+
+// WithJSONLinkAttributes is the Option equivalent of SiteMapperOptions.SetJSONLinkAttributes.
+func WithJSONLinkAttributes(attributes ...string) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetJSONLinkAttributes(attributes...)
+	}
+}
+
+// WithConcurrency is the Option equivalent of SiteMapperOptions.SetConcurrency.
+func WithConcurrency(n int) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetConcurrency(n)
+	}
+}
+
+// WithUserAgent is the Option equivalent of SiteMapperOptions.SetUserAgent.
+func WithUserAgent(userAgent string) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetUserAgent(userAgent)
+	}
+}
+
+// WithHTTPClient is the Option equivalent of SiteMapperOptions.SetHTTPClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetHTTPClient(client)
+		return nil
+	}
+}
+
+// WithPageFetcher is the Option equivalent of SiteMapperOptions.SetPageFetcher.
+func WithPageFetcher(fetcher func(ctx context.Context, url string) ([]byte, error)) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetPageFetcher(fetcher)
+		return nil
+	}
+}
+
+// WithLinkExtractor is the Option equivalent of SiteMapperOptions.SetLinkExtractor.
+func WithLinkExtractor(extractor LinkExtractor) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetLinkExtractor(extractor)
+		return nil
+	}
+}
+
+// WithProxyURL is the Option equivalent of SiteMapperOptions.SetProxyURL.
+func WithProxyURL(proxyURL string) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetProxyURL(proxyURL)
+	}
+}
+
+// WithRequestHeaders is the Option equivalent of SiteMapperOptions.SetRequestHeaders.
+func WithRequestHeaders(headers http.Header) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetRequestHeaders(headers)
+	}
+}
+
+// WithRequestHeader is the Option equivalent of SiteMapperOptions.AddRequestHeader.
+func WithRequestHeader(key string, value string) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.AddRequestHeader(key, value)
+	}
+}
+
+// WithBasicAuth is the Option equivalent of SiteMapperOptions.SetBasicAuth.
+func WithBasicAuth(username string, password string) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetBasicAuth(username, password)
+	}
+}
+
+// WithCookieJar is the Option equivalent of SiteMapperOptions.SetCookieJar.
+func WithCookieJar(jar http.CookieJar) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetCookieJar(jar)
+		return nil
+	}
+}
+
+// WithCookies is the Option equivalent of SiteMapperOptions.SetCookies.
+func WithCookies(cookies []*http.Cookie) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetCookies(cookies)
+	}
+}
+
+// WithFollowRedirects is the Option equivalent of SiteMapperOptions.SetFollowRedirects.
+func WithFollowRedirects(follow bool) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetFollowRedirects(follow)
+		return nil
+	}
+}
+
+// WithAlwaysUpdateLastMod is the Option equivalent of SiteMapperOptions.SetAlwaysUpdateLastMod.
+func WithAlwaysUpdateLastMod(always bool) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetAlwaysUpdateLastMod(always)
+		return nil
+	}
+}
+
+// WithUseConditionalRequests is the Option equivalent of SiteMapperOptions.SetUseConditionalRequests.
+func WithUseConditionalRequests(use bool) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetUseConditionalRequests(use)
+		return nil
+	}
+}
+
+// WithRequestTimeout is the Option equivalent of SiteMapperOptions.SetRequestTimeout.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetRequestTimeout(timeout)
+	}
+}
+
+// WithMaxDepth is the Option equivalent of SiteMapperOptions.SetMaxDepth.
+func WithMaxDepth(depth int) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetMaxDepth(depth)
+		return nil
+	}
+}
+
+// WithCrawlDelay is the Option equivalent of SiteMapperOptions.SetCrawlDelay.
+func WithCrawlDelay(delay time.Duration) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetCrawlDelay(delay)
+	}
+}
+
+// WithMaxCrawlDuration is the Option equivalent of SiteMapperOptions.SetMaxCrawlDuration.
+func WithMaxCrawlDuration(duration time.Duration) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetMaxCrawlDuration(duration)
+	}
+}
+
+// WithRequestBudget is the Option equivalent of SiteMapperOptions.SetRequestBudget.
+func WithRequestBudget(n int) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetRequestBudget(n)
+	}
+}
+
+// WithFallbackThreshold is the Option equivalent of SiteMapperOptions.SetFallbackThreshold.
+func WithFallbackThreshold(percent float64) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetFallbackThreshold(percent)
+	}
+}
+
+// WithIncludePatterns is the Option equivalent of SiteMapperOptions.SetIncludePatterns.
+func WithIncludePatterns(patterns ...string) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetIncludePatterns(patterns...)
+	}
+}
+
+// WithExcludePatterns is the Option equivalent of SiteMapperOptions.SetExcludePatterns.
+func WithExcludePatterns(patterns ...string) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetExcludePatterns(patterns...)
+	}
+}
+
+// WithSoft404Pattern is the Option equivalent of SiteMapperOptions.SetSoft404Pattern.
+func WithSoft404Pattern(pattern string) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetSoft404Pattern(pattern)
+	}
+}
+
+// WithScopePatterns is the Option equivalent of SiteMapperOptions.SetScopePatterns.
+func WithScopePatterns(patterns ...string) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetScopePatterns(patterns...)
+	}
+}
+
+// WithPathPatternsToCollapse is the Option equivalent of SiteMapperOptions.SetPathPatternsToCollapse.
+func WithPathPatternsToCollapse(patterns ...string) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetPathPatternsToCollapse(patterns...)
+	}
+}
+
+// WithTrailingSlashPolicy is the Option equivalent of SiteMapperOptions.SetTrailingSlashPolicy.
+func WithTrailingSlashPolicy(policy TrailingSlashPolicy) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetTrailingSlashPolicy(policy)
+	}
+}
+
+// WithStripQueryParams is the Option equivalent of SiteMapperOptions.SetStripQueryParams.
+func WithStripQueryParams(strip bool) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetStripQueryParams(strip)
+		return nil
+	}
+}
+
+// WithIgnoreQueryParams is the Option equivalent of SiteMapperOptions.SetIgnoreQueryParams.
+func WithIgnoreQueryParams(params ...string) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetIgnoreQueryParams(params...)
+	}
+}
+
+// WithChangeDetectionMode is the Option equivalent of SiteMapperOptions.SetChangeDetectionMode.
+func WithChangeDetectionMode(mode ChangeDetectionMode) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetChangeDetectionMode(mode)
+	}
+}
+
+// WithChecksumIgnoreSelectors is the Option equivalent of SiteMapperOptions.SetChecksumIgnoreSelectors.
+func WithChecksumIgnoreSelectors(selectors ...string) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetChecksumIgnoreSelectors(selectors...)
+	}
+}
+
+// WithMaxResponseBytes is the Option equivalent of SiteMapperOptions.SetMaxResponseBytes.
+func WithMaxResponseBytes(n int64) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetMaxResponseBytes(n)
+	}
+}
+
+// WithAllowedContentTypes is the Option equivalent of SiteMapperOptions.SetAllowedContentTypes.
+func WithAllowedContentTypes(contentTypes ...string) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetAllowedContentTypes(contentTypes...)
+	}
+}
+
+// WithRespectCanonical is the Option equivalent of SiteMapperOptions.SetRespectCanonical.
+func WithRespectCanonical(respect bool) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetRespectCanonical(respect)
+		return nil
+	}
+}
+
+// WithRespectNofollow is the Option equivalent of SiteMapperOptions.SetRespectNofollow.
+func WithRespectNofollow(respect bool) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetRespectNofollow(respect)
+		return nil
+	}
+}
+
+// WithIncludeExternalImages is the Option equivalent of SiteMapperOptions.SetIncludeExternalImages.
+func WithIncludeExternalImages(include bool) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetIncludeExternalImages(include)
+		return nil
+	}
+}
+
+// WithExtractVideos is the Option equivalent of SiteMapperOptions.SetExtractVideos.
+func WithExtractVideos(extract bool) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetExtractVideos(extract)
+		return nil
+	}
+}
+
+// WithIncludeExternalLinksInGraph is the Option equivalent of SiteMapperOptions.SetIncludeExternalLinksInGraph.
+func WithIncludeExternalLinksInGraph(include bool) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetIncludeExternalLinksInGraph(include)
+		return nil
+	}
+}
+
+// WithRespectRobotsTxt is the Option equivalent of SiteMapperOptions.SetRespectRobotsTxt.
+func WithRespectRobotsTxt(respect bool) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetRespectRobotsTxt(respect)
+		return nil
+	}
+}
+
+// WithImportLinkedSitemaps is the Option equivalent of SiteMapperOptions.SetImportLinkedSitemaps.
+func WithImportLinkedSitemaps(importSitemaps bool) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetImportLinkedSitemaps(importSitemaps)
+		return nil
+	}
+}
+
+// WithChangeFreq is the Option equivalent of SiteMapperOptions.SetChangeFreq.
+func WithChangeFreq(pattern string, freq string) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetChangeFreq(pattern, freq)
+	}
+}
+
+// WithPriority is the Option equivalent of SiteMapperOptions.SetPriority.
+func WithPriority(pattern string, priority float64) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetPriority(pattern, priority)
+	}
+}
+
+// WithMaxURLsPerSitemap is the Option equivalent of SiteMapperOptions.SetMaxURLsPerSitemap.
+func WithMaxURLsPerSitemap(n int) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetMaxURLsPerSitemap(n)
+	}
+}
+
+// WithLastModFormat is the Option equivalent of SiteMapperOptions.SetLastModFormat.
+func WithLastModFormat(layout string) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetLastModFormat(layout)
+	}
+}
+
+// WithSitemapIndent is the Option equivalent of SiteMapperOptions.SetSitemapIndent.
+func WithSitemapIndent(indent string) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetSitemapIndent(indent)
+	}
+}
+
+// WithSitemapOrdering is the Option equivalent of SiteMapperOptions.SetSitemapOrdering.
+func WithSitemapOrdering(ordering SitemapOrdering) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetSitemapOrdering(ordering)
+	}
+}
+
+// WithFilterMode is the Option equivalent of SiteMapperOptions.SetFilterMode.
+func WithFilterMode(mode FilterMode) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetFilterMode(mode)
+	}
+}
+
+// WithAlwaysIncludeRoot is the Option equivalent of SiteMapperOptions.SetAlwaysIncludeRoot.
+func WithAlwaysIncludeRoot(include bool) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetAlwaysIncludeRoot(include)
+		return nil
+	}
+}
+
+// WithInfoLogger is the Option equivalent of SiteMapperOptions.SetInfoLogger.
+func WithInfoLogger(logger func(string)) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetInfoLogger(logger)
+		return nil
+	}
+}
+
+// WithErrorLogger is the Option equivalent of SiteMapperOptions.SetErrorLogger.
+func WithErrorLogger(logger func(error)) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetErrorLogger(logger)
+		return nil
+	}
+}
+
+// WithCheckpointEvery is the Option equivalent of SiteMapperOptions.SetCheckpointEvery.
+func WithCheckpointEvery(n int) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetCheckpointEvery(n)
+	}
+}
+
+// WithCheckpointCallback is the Option equivalent of SiteMapperOptions.SetCheckpointCallback.
+func WithCheckpointCallback(callback func(*SiteMapper)) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetCheckpointCallback(callback)
+		return nil
+	}
+}
+
+// WithPreCrawlCallback is the Option equivalent of SiteMapperOptions.SetPreCrawlCallback.
+func WithPreCrawlCallback(callback func(*SiteMapper)) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetPreCrawlCallback(callback)
+		return nil
+	}
+}
+
+// WithMetrics is the Option equivalent of SiteMapperOptions.SetMetrics.
+func WithMetrics(metrics Metrics) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetMetrics(metrics)
+		return nil
+	}
+}
+
+// WithAutoPing is the Option equivalent of SiteMapperOptions.SetAutoPing.
+func WithAutoPing(sitemapURL string) Option {
+	return func(options *SiteMapperOptions) error {
+		return options.SetAutoPing(sitemapURL)
+	}
+}
+
+// WithRefreshSecret is the Option equivalent of SiteMapperOptions.SetRefreshSecret.
+func WithRefreshSecret(secret string) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetRefreshSecret(secret)
+		return nil
+	}
+}
+
+// WithCallbackFunction is the Option equivalent of SiteMapperOptions.SetCallbackFunction.
+func WithCallbackFunction(callback func(*SiteMapper)) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetCallbackFunction(callback)
+		return nil
+	}
+}
+
+// WithPageCallback is the Option equivalent of SiteMapperOptions.SetPageCallback.
+func WithPageCallback(callback func(url string, statusCode int, body []byte)) Option {
+	return func(options *SiteMapperOptions) error {
+		options.SetPageCallback(callback)
+		return nil
+	}
+}
@@ -0,0 +1,67 @@
+package sitemapper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewSiteMapperWithOptions(t *testing.T) {
+	mapper, err := NewSiteMapperWithOptions(
+		WithDomain("https://example.com/"),
+		WithDurationBeforeFirstCrawl(time.Hour),
+		WithStartingURL("/blog"),
+		WithConcurrency(4),
+		WithRespectNofollow(true),
+		WithMaxURLsPerSitemap(100),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mapper.Stop()
+
+	if mapper.domain != "https://example.com" {
+		t.Errorf("Expected domain to be 'https://example.com', got '%s'", mapper.domain)
+	}
+
+	if mapper.startingURL != "/blog" {
+		t.Errorf("Expected starting URL to be '/blog', got '%s'", mapper.startingURL)
+	}
+
+	if mapper.spider.concurrency != 4 {
+		t.Errorf("Expected concurrency to be 4, got %d", mapper.spider.concurrency)
+	}
+
+	if !mapper.spider.respectNofollow {
+		t.Error("Expected respectNofollow to be true")
+	}
+
+	if mapper.maxURLsPerSitemap != 100 {
+		t.Errorf("Expected maxURLsPerSitemap to be 100, got %d", mapper.maxURLsPerSitemap)
+	}
+}
+
+func TestNewSiteMapperWithOptionsError(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"Invalid domain", []Option{WithDomain("ftp://example.com")}},
+		{"Invalid concurrency", []Option{WithDomain("https://example.com"), WithConcurrency(0)}},
+		{"Invalid pattern", []Option{WithExcludePatterns("[")}},
+		{"Zero crawl interval", []Option{WithCrawlInterval(0)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mapper, err := NewSiteMapperWithOptions(test.opts...)
+			if err == nil {
+				mapper.Stop()
+				t.Fatal("Expected an error, got nil")
+			}
+
+			if mapper != nil {
+				t.Error("Expected no SiteMapper to be created")
+			}
+		})
+	}
+}